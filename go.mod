@@ -1,8 +1,3 @@
 module github.com/paw1a/golox
 
 go 1.18
-
-require (
-	github.com/eiannone/keyboard v0.0.0-20220611211555-0d226195f203 // indirect
-	golang.org/x/sys v0.0.0-20220808155132-1c4a2a72c664 // indirect
-)
@@ -4,65 +4,151 @@ import "github.com/paw1a/golox/internal/lexing"
 
 type Expr interface {
 	Printer
+	Span() Span
 }
 
 type BinaryExpr struct {
 	LeftExpr  Expr
 	Operator  lexing.Token
 	RightExpr Expr
+	Range     Span
 }
 
 type UnaryExpr struct {
 	Operator  lexing.Token
 	RightExpr Expr
+	Range     Span
 }
 
 type LiteralExpr struct {
 	LiteralValue interface{}
+	Range        Span
 }
 
 type GroupingExpr struct {
-	Expr Expr
+	Expr  Expr
+	Range Span
 }
 
 type VariableExpr struct {
-	Name lexing.Token
+	Name  lexing.Token
+	Range Span
 }
 
 type AssignExpr struct {
 	Variable    Expr
 	Initializer Expr
+	Range       Span
+}
+
+type CompoundAssignExpr struct {
+	Variable Expr
+	Operator lexing.Token
+	Value    Expr
+	Range    Span
+}
+
+type IncrementExpr struct {
+	Variable Expr
+	Operator lexing.Token
+	Prefix   bool
+	Range    Span
 }
 
 type TernaryExpr struct {
 	Condition Expr
 	TrueExpr  Expr
 	FalseExpr Expr
+	Range     Span
 }
 
 type LogicalExpr struct {
 	LeftExpr  Expr
 	Operator  lexing.Token
 	RightExpr Expr
+	Range     Span
 }
 
 type CallExpr struct {
 	Callee    Expr
 	Paren     lexing.Token
 	Arguments []Expr
+	Optional  bool
+	Range     Span
 }
 
 type ArrayExpr struct {
 	Elements []Expr
+	Range    Span
 }
 
 type IndexExpr struct {
 	Array     Expr
 	Bracket   lexing.Token
 	IndexExpr Expr
+	Optional  bool
+	Range     Span
+}
+
+type MapExpr struct {
+	Brace  lexing.Token
+	Keys   []Expr
+	Values []Expr
+	Range  Span
+}
+
+type ArrayPattern struct {
+	Bracket  lexing.Token
+	Elements []Expr
+	Rest     Expr
+	Range    Span
+}
+
+type MapPattern struct {
+	Brace  lexing.Token
+	Keys   []lexing.Token
+	Values []Expr
+	Range  Span
+}
+
+type GetExpr struct {
+	Object   Expr
+	Name     lexing.Token
+	Optional bool
+	Range    Span
+}
+
+type NamedArgument struct {
+	Name  lexing.Token
+	Value Expr
+	Range Span
+}
+
+type SpreadExpr struct {
+	Ellipsis lexing.Token
+	Expr     Expr
+	Range    Span
 }
 
 type LambdaExpr struct {
 	Params    []lexing.Token
+	Defaults  []Expr
+	Rest      bool
 	Statement BlockStmt
+	Range     Span
+}
+
+type MatchExpr struct {
+	Keyword lexing.Token
+	Subject Expr
+	Arms    []MatchArm
+	Range   Span
+}
+
+type MatchArm struct {
+	Keyword  lexing.Token
+	Patterns []Expr
+	Guard    Expr
+	Body     Node
+	Range    Span
 }
@@ -0,0 +1,139 @@
+package ast
+
+import (
+	"github.com/paw1a/golox/internal/lexing"
+)
+
+// Node is any expression or statement.
+type Node interface {
+	Printer
+	Span() Span
+}
+
+// Inspect traverses the tree rooted at node in depth-first order, calling
+// f for every node. Children are not visited when f returns false.
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) {
+		return
+	}
+
+	switch node.(type) {
+	case BinaryExpr:
+		Inspect(node.(BinaryExpr).LeftExpr, f)
+		Inspect(node.(BinaryExpr).RightExpr, f)
+	case UnaryExpr:
+		Inspect(node.(UnaryExpr).RightExpr, f)
+	case GroupingExpr:
+		Inspect(node.(GroupingExpr).Expr, f)
+	case AssignExpr:
+		Inspect(node.(AssignExpr).Variable, f)
+		Inspect(node.(AssignExpr).Initializer, f)
+	case CompoundAssignExpr:
+		Inspect(node.(CompoundAssignExpr).Variable, f)
+		Inspect(node.(CompoundAssignExpr).Value, f)
+	case IncrementExpr:
+		Inspect(node.(IncrementExpr).Variable, f)
+	case TernaryExpr:
+		Inspect(node.(TernaryExpr).Condition, f)
+		Inspect(node.(TernaryExpr).TrueExpr, f)
+		Inspect(node.(TernaryExpr).FalseExpr, f)
+	case LogicalExpr:
+		Inspect(node.(LogicalExpr).LeftExpr, f)
+		Inspect(node.(LogicalExpr).RightExpr, f)
+	case CallExpr:
+		Inspect(node.(CallExpr).Callee, f)
+		for _, argument := range node.(CallExpr).Arguments {
+			Inspect(argument, f)
+		}
+	case ArrayExpr:
+		for _, element := range node.(ArrayExpr).Elements {
+			Inspect(element, f)
+		}
+	case IndexExpr:
+		Inspect(node.(IndexExpr).Array, f)
+		Inspect(node.(IndexExpr).IndexExpr, f)
+	case MapExpr:
+		for index, key := range node.(MapExpr).Keys {
+			Inspect(key, f)
+			Inspect(node.(MapExpr).Values[index], f)
+		}
+	case ArrayPattern:
+		for _, element := range node.(ArrayPattern).Elements {
+			Inspect(element, f)
+		}
+		Inspect(node.(ArrayPattern).Rest, f)
+	case MapPattern:
+		for _, value := range node.(MapPattern).Values {
+			Inspect(value, f)
+		}
+	case GetExpr:
+		Inspect(node.(GetExpr).Object, f)
+	case NamedArgument:
+		Inspect(node.(NamedArgument).Value, f)
+	case SpreadExpr:
+		Inspect(node.(SpreadExpr).Expr, f)
+	case LambdaExpr:
+		for _, defaultValue := range node.(LambdaExpr).Defaults {
+			Inspect(defaultValue, f)
+		}
+		Inspect(node.(LambdaExpr).Statement, f)
+	case MatchExpr:
+		Inspect(node.(MatchExpr).Subject, f)
+		inspectArms(node.(MatchExpr).Arms, f)
+	case ExpressionStmt:
+		Inspect(node.(ExpressionStmt).Expr, f)
+	case PrintStmt:
+		Inspect(node.(PrintStmt).Expr, f)
+	case VarDeclarationStmt:
+		Inspect(node.(VarDeclarationStmt).Initializer, f)
+	case VarPatternStmt:
+		Inspect(node.(VarPatternStmt).Pattern, f)
+		Inspect(node.(VarPatternStmt).Initializer, f)
+	case BlockStmt:
+		for _, stmt := range node.(BlockStmt).Stmts {
+			Inspect(stmt, f)
+		}
+	case IfStmt:
+		Inspect(node.(IfStmt).ConditionExpr, f)
+		Inspect(node.(IfStmt).IfStatement, f)
+		Inspect(node.(IfStmt).ElseStatement, f)
+	case ForStmt:
+		Inspect(node.(ForStmt).InitializerStmt, f)
+		Inspect(node.(ForStmt).ConditionExpr, f)
+		Inspect(node.(ForStmt).IncrementExpr, f)
+		Inspect(node.(ForStmt).Statement, f)
+	case FunDeclarationStmt:
+		for _, defaultValue := range node.(FunDeclarationStmt).Defaults {
+			Inspect(defaultValue, f)
+		}
+		Inspect(node.(FunDeclarationStmt).Statement, f)
+	case ReturnStmt:
+		Inspect(node.(ReturnStmt).Expr, f)
+	case MatchStmt:
+		Inspect(node.(MatchStmt).Subject, f)
+		inspectArms(node.(MatchStmt).Arms, f)
+	}
+}
+
+func inspectArms(arms []MatchArm, f func(Node) bool) {
+	for _, arm := range arms {
+		for _, pattern := range arm.Patterns {
+			Inspect(pattern, f)
+		}
+		Inspect(arm.Guard, f)
+		Inspect(arm.Body, f)
+	}
+}
+
+// PatternNames returns the names a declaration pattern binds, in source
+// order.
+func PatternNames(pattern Expr) []lexing.Token {
+	var names []lexing.Token
+	Inspect(pattern, func(node Node) bool {
+		if variable, ok := node.(VariableExpr); ok {
+			names = append(names, variable.Name)
+		}
+		return true
+	})
+	return names
+}
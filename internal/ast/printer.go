@@ -101,10 +101,46 @@ func (expr IndexExpr) Print() string {
 	return ""
 }
 
+func (expr CompoundAssignExpr) Print() string {
+	return ""
+}
+
+func (expr IncrementExpr) Print() string {
+	return ""
+}
+
+func (expr MapExpr) Print() string {
+	return ""
+}
+
+func (expr ArrayPattern) Print() string {
+	return ""
+}
+
+func (expr MapPattern) Print() string {
+	return ""
+}
+
+func (expr GetExpr) Print() string {
+	return ""
+}
+
+func (expr NamedArgument) Print() string {
+	return ""
+}
+
+func (expr SpreadExpr) Print() string {
+	return ""
+}
+
 func (expr LambdaExpr) Print() string {
 	return ""
 }
 
+func (expr MatchExpr) Print() string {
+	return ""
+}
+
 func (stmt ExpressionStmt) Print() string {
 	var buffer bytes.Buffer
 
@@ -116,6 +152,17 @@ func (stmt ExpressionStmt) Print() string {
 	return buffer.String()
 }
 
+func (stmt PrintStmt) Print() string {
+	var buffer bytes.Buffer
+
+	buffer.WriteString(" (")
+	buffer.WriteString("print ")
+	buffer.WriteString(stmt.Expr.Print())
+	buffer.WriteString(") ")
+
+	return buffer.String()
+}
+
 func (stmt VarDeclarationStmt) Print() string {
 	var buffer bytes.Buffer
 
@@ -131,6 +178,10 @@ func (stmt VarDeclarationStmt) Print() string {
 	return buffer.String()
 }
 
+func (stmt VarPatternStmt) Print() string {
+	return ""
+}
+
 func (stmt BlockStmt) Print() string {
 	var buffer bytes.Buffer
 
@@ -181,3 +232,7 @@ func (stmt FunDeclarationStmt) Print() string {
 func (stmt ReturnStmt) Print() string {
 	return ""
 }
+
+func (stmt MatchStmt) Print() string {
+	return ""
+}
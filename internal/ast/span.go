@@ -0,0 +1,158 @@
+package ast
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/lexing"
+)
+
+// Span is the range of source text a node was parsed from. End points
+// just past the last byte of the node.
+type Span struct {
+	File  string
+	Start lexing.Location
+	End   lexing.Location
+}
+
+func (s Span) String() string {
+	if s.File == "" {
+		return fmt.Sprintf("%d:%d-%d:%d", s.Start.Line, s.Start.Column, s.End.Line, s.End.Column)
+	}
+	return fmt.Sprintf("%s:%d:%d-%d:%d", s.File, s.Start.Line, s.Start.Column, s.End.Line, s.End.Column)
+}
+
+// Contains reports whether the location lies within the span.
+func (s Span) Contains(location lexing.Location) bool {
+	return s.Start.Offset <= location.Offset && location.Offset < s.End.Offset
+}
+
+func (expr BinaryExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr UnaryExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr LiteralExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr GroupingExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr VariableExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr AssignExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr TernaryExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr LogicalExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr CallExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr ArrayExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr IndexExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr MapExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr GetExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr CompoundAssignExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr IncrementExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr ArrayPattern) Span() Span {
+	return expr.Range
+}
+
+func (expr MapPattern) Span() Span {
+	return expr.Range
+}
+
+func (expr NamedArgument) Span() Span {
+	return expr.Range
+}
+
+func (expr SpreadExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr LambdaExpr) Span() Span {
+	return expr.Range
+}
+
+func (expr MatchExpr) Span() Span {
+	return expr.Range
+}
+
+func (stmt ExpressionStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt PrintStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt VarDeclarationStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt VarPatternStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt BlockStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt IfStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt ForStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt BreakStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt ContinueStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt FunDeclarationStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt ReturnStmt) Span() Span {
+	return stmt.Range
+}
+
+func (stmt MatchStmt) Span() Span {
+	return stmt.Range
+}
@@ -6,25 +6,42 @@ import (
 
 type Stmt interface {
 	Printer
+	Span() Span
 }
 
 type ExpressionStmt struct {
-	Expr Expr
+	Expr  Expr
+	Range Span
+}
+
+type PrintStmt struct {
+	Expr  Expr
+	Range Span
+}
+
+type VarPatternStmt struct {
+	Pattern     Expr
+	Initializer Expr
+	Range       Span
 }
 
 type BlockStmt struct {
 	Stmts []Stmt
+	Range Span
 }
 
 type VarDeclarationStmt struct {
 	Name        lexing.Token
 	Initializer Expr
+	Const       bool
+	Range       Span
 }
 
 type IfStmt struct {
 	ConditionExpr Expr
 	IfStatement   Stmt
 	ElseStatement Stmt
+	Range         Span
 }
 
 type ForStmt struct {
@@ -32,21 +49,35 @@ type ForStmt struct {
 	ConditionExpr   Expr
 	IncrementExpr   Expr
 	Statement       Stmt
+	Range           Span
 }
 
 type BreakStmt struct {
+	Range Span
 }
 
 type ContinueStmt struct {
+	Range Span
 }
 
 type FunDeclarationStmt struct {
 	Name      lexing.Token
 	Params    []lexing.Token
+	Defaults  []Expr
+	Rest      bool
 	Statement BlockStmt
+	Range     Span
 }
 
 type ReturnStmt struct {
 	ReturnToken lexing.Token
 	Expr        Expr
+	Range       Span
+}
+
+type MatchStmt struct {
+	Keyword lexing.Token
+	Subject Expr
+	Arms    []MatchArm
+	Range   Span
 }
@@ -0,0 +1,63 @@
+package conformance
+
+import (
+	"context"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"io"
+	"strings"
+)
+
+// Backend executes Lox scripts. Every backend must pass the same corpus.
+type Backend interface {
+	Name() string
+	// Run runs the script writing its output to out. It returns a
+	// *CompileErrors if the script can't be run, a *RuntimeError if it
+	// stopped on an error and a *runtime.ExitError if it called exit.
+	Run(filename string, source string, out io.Writer) error
+}
+
+var Backends = []Backend{
+	TreeWalker{},
+}
+
+// TreeWalker runs scripts with the runtime.Interpreter.
+type TreeWalker struct {
+}
+
+func (b TreeWalker) Name() string {
+	return "treewalk"
+}
+
+func (b TreeWalker) Run(filename string, source string, out io.Writer) error {
+	lexer := lexing.NewLexer(source)
+	lexer.ScanTokens()
+	if len(lexer.Errors) != 0 {
+		return &CompileErrors{Errors: lexer.Errors}
+	}
+
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	parser.File = filename
+	statements := parser.Parse()
+	if len(parser.Errors) != 0 {
+		return &CompileErrors{Errors: parser.Errors}
+	}
+
+	resolver := resolving.NewResolver()
+	resolver.Resolve(statements)
+	if errs := resolver.Errors(); len(errs) != 0 {
+		return &CompileErrors{Errors: errs}
+	}
+
+	inter := runtime.NewInterpreter()
+	inter.SetOutput(out)
+	inter.SetInput(strings.NewReader(""))
+
+	err := inter.Interpret(context.Background(), statements)
+	if e, ok := err.(*runtime.Error); ok {
+		return &RuntimeError{Line: e.Token.Line, Message: e.Message, Err: e}
+	}
+	return err
+}
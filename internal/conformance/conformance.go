@@ -0,0 +1,232 @@
+package conformance
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"strconv"
+	"strings"
+)
+
+// Expectation is an expected line of output, read from a comment of the
+// script:
+//
+//	print 1; // expect: 1
+//	1 + nil; // expect runtime error: number or string operands expected
+//	var = 1; // expect error: expect variable name
+//	exit(2); // expect exit: 2
+//
+// Runtime errors must be raised at the line of their comment, errors are
+// lexing or parsing errors and are matched by message only.
+type Expectation struct {
+	Line  int
+	Kind  Kind
+	Value string
+}
+
+type Kind int
+
+const (
+	Output Kind = iota
+	Runtime
+	Compile
+	Exit
+)
+
+var markers = []struct {
+	prefix string
+	kind   Kind
+}{
+	{"// expect: ", Output},
+	{"// expect runtime error: ", Runtime},
+	{"// expect error: ", Compile},
+	{"// expect exit: ", Exit},
+}
+
+// ParseExpectations returns the expectations of the script in source order.
+func ParseExpectations(source string) []Expectation {
+	var expectations []Expectation
+
+	scanner := bufio.NewScanner(strings.NewReader(source))
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		for _, marker := range markers {
+			if index := strings.Index(text, marker.prefix); index >= 0 {
+				expectations = append(expectations, Expectation{
+					Line:  line,
+					Kind:  marker.kind,
+					Value: strings.TrimSpace(text[index+len(marker.prefix):]),
+				})
+				break
+			}
+		}
+	}
+
+	return expectations
+}
+
+// Check runs the script with the backend and returns a description of
+// every way its behaviour differs from the expectations.
+func Check(backend Backend, filename string, source string) []string {
+	expectations := ParseExpectations(source)
+
+	var output strings.Builder
+	err := backend.Run(filename, source, &output)
+
+	var failures []string
+	var expectedOutput []Expectation
+	var expectedErrors []Expectation
+	var runtimeError *Expectation
+	var exit *Expectation
+	for i, expectation := range expectations {
+		switch expectation.Kind {
+		case Output:
+			expectedOutput = append(expectedOutput, expectation)
+		case Compile:
+			expectedErrors = append(expectedErrors, expectation)
+		case Runtime:
+			if runtimeError != nil {
+				failures = append(failures, fmt.Sprintf("line %d: more than one runtime error expected", expectation.Line))
+			}
+			runtimeError = &expectations[i]
+		case Exit:
+			exit = &expectations[i]
+		}
+	}
+
+	failures = append(failures, checkOutput(output.String(), expectedOutput)...)
+	failures = append(failures, checkError(err, expectedErrors, runtimeError, exit)...)
+	return failures
+}
+
+func checkOutput(output string, expected []Expectation) []string {
+	var failures []string
+
+	lines := strings.Split(output, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	for i, line := range lines {
+		if i >= len(expected) {
+			failures = append(failures, fmt.Sprintf("unexpected output %q", line))
+			continue
+		}
+		if line != expected[i].Value {
+			failures = append(failures, fmt.Sprintf("line %d: expected output %q, got %q",
+				expected[i].Line, expected[i].Value, line))
+		}
+	}
+	for _, expectation := range expected[min(len(lines), len(expected)):] {
+		failures = append(failures, fmt.Sprintf("line %d: missing output %q", expectation.Line, expectation.Value))
+	}
+
+	return failures
+}
+
+func checkError(err error, compileErrors []Expectation, runtimeError *Expectation, exit *Expectation) []string {
+	switch err.(type) {
+	case nil:
+	case *runtime.ExitError:
+		code := strconv.Itoa(err.(*runtime.ExitError).Code)
+		switch {
+		case exit == nil:
+			return []string{fmt.Sprintf("unexpected exit with status %s", code)}
+		case code != exit.Value:
+			return []string{fmt.Sprintf("line %d: expected exit status %s, got %s", exit.Line, exit.Value, code)}
+		}
+		return nil
+	case *RuntimeError:
+		e := err.(*RuntimeError)
+		switch {
+		case runtimeError == nil:
+			return []string{fmt.Sprintf("unexpected runtime error: %s", e.Err)}
+		case e.Line != runtimeError.Line || e.Message != runtimeError.Value:
+			return []string{fmt.Sprintf("line %d: expected runtime error %q, got %q at line %d",
+				runtimeError.Line, runtimeError.Value, e.Message, e.Line)}
+		}
+		return nil
+	case *CompileErrors:
+		var failures []string
+		messages := err.(*CompileErrors).Messages()
+		for _, expectation := range compileErrors {
+			if !contains(messages, expectation.Value) {
+				failures = append(failures, fmt.Sprintf("line %d: expected error %q", expectation.Line, expectation.Value))
+			}
+		}
+		if len(compileErrors) == 0 {
+			failures = append(failures, fmt.Sprintf("unexpected error: %s", err))
+		}
+		return failures
+	default:
+		return []string{fmt.Sprintf("unexpected error: %s", err)}
+	}
+
+	var failures []string
+	if exit != nil {
+		failures = append(failures, fmt.Sprintf("line %d: missing exit with status %s", exit.Line, exit.Value))
+	}
+	if runtimeError != nil {
+		failures = append(failures, fmt.Sprintf("line %d: missing runtime error %q", runtimeError.Line, runtimeError.Value))
+	}
+	for _, expectation := range compileErrors {
+		failures = append(failures, fmt.Sprintf("line %d: missing error %q", expectation.Line, expectation.Value))
+	}
+	return failures
+}
+
+func contains(messages []string, message string) bool {
+	for _, m := range messages {
+		if m == message {
+			return true
+		}
+	}
+	return false
+}
+
+func min(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// CompileErrors are the lexing or parsing errors reported for a script.
+type CompileErrors struct {
+	Errors []error
+}
+
+func (e *CompileErrors) Error() string {
+	return strings.Join(e.Messages(), "; ")
+}
+
+func (e *CompileErrors) Messages() []string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		switch err.(type) {
+		case *lexing.Error:
+			messages[i] = err.(*lexing.Error).Message
+		case *parsing.Error:
+			messages[i] = err.(*parsing.Error).Message
+		case resolving.Diagnostic:
+			messages[i] = err.(resolving.Diagnostic).Message
+		default:
+			messages[i] = err.Error()
+		}
+	}
+	return messages
+}
+
+// RuntimeError is the error a script stopped with.
+type RuntimeError struct {
+	Line    int
+	Message string
+	Err     error
+}
+
+func (e *RuntimeError) Error() string {
+	return e.Err.Error()
+}
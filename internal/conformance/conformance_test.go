@@ -0,0 +1,64 @@
+package conformance
+
+import (
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// corpus lists the directories of scripts checked against every backend.
+var corpus = []string{
+	"../../examples",
+	"../../test",
+}
+
+// skipped lists the scripts whose behaviour can't be checked, with the
+// reason. Every other script is checked, one without expectations must run
+// without output and errors.
+var skipped = map[string]string{
+	"examples/benchmark.lox": "prints timings and runs for too long",
+	"examples/game.lox":      "interactive, runs until killed",
+}
+
+func TestConformance(t *testing.T) {
+	var files []string
+	for _, dir := range corpus {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(path, ".lox") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, backend := range Backends {
+		backend := backend
+		t.Run(backend.Name(), func(t *testing.T) {
+			for _, file := range files {
+				file := file
+				name := strings.TrimPrefix(filepath.ToSlash(file), "../../")
+				t.Run(name, func(t *testing.T) {
+					source, err := ioutil.ReadFile(file)
+					if err != nil {
+						t.Fatal(err)
+					}
+					if reason, ok := skipped[name]; ok {
+						t.Skip(reason)
+					}
+
+					for _, failure := range Check(backend, file, string(source)) {
+						t.Error(failure)
+					}
+				})
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package coverage
+
+import (
+	"github.com/paw1a/golox/internal/ast"
+	"sort"
+	"strings"
+)
+
+// Coverage is a runtime.BranchHook counting how many times every statement
+// was executed and every conditional went either way. The program is walked
+// up front so that code which never runs is reported too.
+type Coverage struct {
+	file  string
+	lines []string
+
+	statements map[ast.Span]*statement
+	branches   map[ast.Span]*branch
+}
+
+type statement struct {
+	line int
+	hits int
+}
+
+// branch is a conditional with two outcomes: the condition held (or the
+// right operand of a logical operator was evaluated) or it did not.
+type branch struct {
+	line     int
+	kind     string
+	taken    int
+	notTaken int
+}
+
+func (b *branch) evaluated() bool {
+	return b.taken+b.notTaken > 0
+}
+
+func (c *Coverage) BeforeStmt(stmt ast.Stmt) {
+	if s, ok := c.statements[stmt.Span()]; ok {
+		s.hits++
+	}
+}
+
+func (c *Coverage) Branch(node ast.Node, taken bool) {
+	b, ok := c.branches[node.Span()]
+	if !ok {
+		return
+	}
+
+	if taken {
+		b.taken++
+	} else {
+		b.notTaken++
+	}
+}
+
+// Line is the coverage of a single source line.
+type Line struct {
+	Number int
+	Source string
+	// Hits is the number of times the most executed statement starting
+	// on the line ran, -1 if no statement starts on it.
+	Hits     int
+	Branches []Branch
+}
+
+type Branch struct {
+	Kind      string
+	Evaluated bool
+	Taken     int
+	NotTaken  int
+}
+
+func (l Line) Executable() bool {
+	return l.Hits >= 0
+}
+
+// Partial reports whether some conditional on the line ran but has only
+// ever gone one way.
+func (l Line) Partial() bool {
+	for _, b := range l.Branches {
+		if b.Taken == 0 || b.NotTaken == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Lines returns the coverage of every line of the source.
+func (c *Coverage) Lines() []Line {
+	lines := make([]Line, len(c.lines))
+	for i, source := range c.lines {
+		lines[i] = Line{
+			Number: i + 1,
+			Source: strings.TrimRight(source, "\r\n"),
+			Hits:   -1,
+		}
+	}
+
+	for _, s := range c.statements {
+		if s.line < 1 || s.line > len(lines) {
+			continue
+		}
+		if line := &lines[s.line-1]; s.hits > line.Hits {
+			line.Hits = s.hits
+		}
+	}
+
+	spans := make([]ast.Span, 0, len(c.branches))
+	for span := range c.branches {
+		spans = append(spans, span)
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i].Start.Offset < spans[j].Start.Offset
+	})
+	for _, span := range spans {
+		b := c.branches[span]
+		if b.line < 1 || b.line > len(lines) {
+			continue
+		}
+		line := &lines[b.line-1]
+		line.Branches = append(line.Branches, Branch{
+			Kind:      b.kind,
+			Evaluated: b.evaluated(),
+			Taken:     b.taken,
+			NotTaken:  b.notTaken,
+		})
+	}
+
+	return lines
+}
+
+func NewCoverage(file string, lines []string, statements []ast.Stmt) *Coverage {
+	c := &Coverage{
+		file:       file,
+		lines:      lines,
+		statements: make(map[ast.Span]*statement),
+		branches:   make(map[ast.Span]*branch),
+	}
+
+	for _, stmt := range statements {
+		ast.Inspect(stmt, func(node ast.Node) bool {
+			line := node.Span().Start.Line
+
+			switch node.(type) {
+			case ast.BlockStmt:
+			case ast.Stmt:
+				c.statements[node.Span()] = &statement{line: line}
+			}
+
+			switch node.(type) {
+			case ast.IfStmt:
+				c.branches[node.Span()] = &branch{line: line, kind: "if"}
+			case ast.ForStmt:
+				c.branches[node.Span()] = &branch{line: line, kind: "loop"}
+			case ast.TernaryExpr:
+				c.branches[node.Span()] = &branch{line: line, kind: "ternary"}
+			case ast.LogicalExpr:
+				c.branches[node.Span()] = &branch{line: line, kind: node.(ast.LogicalExpr).Operator.Lexeme}
+			}
+			return true
+		})
+	}
+
+	return c
+}
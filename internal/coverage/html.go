@@ -0,0 +1,111 @@
+package coverage
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Coverage of {{.File}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 0.5em; white-space: pre; vertical-align: top; }
+td.number, td.hits { text-align: right; color: #888; }
+td.branches { color: #888; }
+tr.covered td.source { background: #dfd; }
+tr.partial td.source { background: #ffd; }
+tr.missed td.source { background: #fdd; }
+</style>
+</head>
+<body>
+<h1>{{.File}}</h1>
+<p>Lines: {{.LinesHit}}/{{.LinesFound}} ({{.LinePercent}}), branches: {{.BranchesHit}}/{{.BranchesFound}} ({{.BranchPercent}})</p>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="number">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="source">{{.Source}}</td><td class="branches">{{.Branches}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+type htmlLine struct {
+	Number   int
+	Hits     string
+	Source   string
+	Branches string
+	Class    string
+}
+
+type htmlReport struct {
+	File          string
+	Lines         []htmlLine
+	LinesFound    int
+	LinesHit      int
+	BranchesFound int
+	BranchesHit   int
+}
+
+func (r htmlReport) LinePercent() string {
+	return percent(r.LinesHit, r.LinesFound)
+}
+
+func (r htmlReport) BranchPercent() string {
+	return percent(r.BranchesHit, r.BranchesFound)
+}
+
+func percent(hit int, found int) string {
+	if found == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(hit)/float64(found))
+}
+
+// WriteHTML writes a standalone page showing the source annotated with
+// hit counts: executed lines are green, lines with a conditional that has
+// only gone one way are yellow and lines that never ran are red.
+func (c *Coverage) WriteHTML(w io.Writer) error {
+	report := htmlReport{File: c.file}
+
+	for _, line := range c.Lines() {
+		l := htmlLine{Number: line.Number, Source: line.Source}
+
+		var branches []string
+		for _, b := range line.Branches {
+			branches = append(branches, fmt.Sprintf("%s %d/%d", b.Kind, b.Taken, b.NotTaken))
+
+			report.BranchesFound += 2
+			if b.Taken > 0 {
+				report.BranchesHit++
+			}
+			if b.NotTaken > 0 {
+				report.BranchesHit++
+			}
+		}
+		l.Branches = strings.Join(branches, ", ")
+
+		if line.Executable() {
+			l.Hits = fmt.Sprint(line.Hits)
+			report.LinesFound++
+
+			switch {
+			case line.Hits == 0:
+				l.Class = "missed"
+			case line.Partial():
+				l.Class = "partial"
+				report.LinesHit++
+			default:
+				l.Class = "covered"
+				report.LinesHit++
+			}
+		}
+
+		report.Lines = append(report.Lines, l)
+	}
+
+	return page.Execute(w, report)
+}
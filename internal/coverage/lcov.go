@@ -0,0 +1,58 @@
+package coverage
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// WriteLCOV writes the coverage in the lcov tracefile format understood
+// by genhtml and most editors and CI services.
+func (c *Coverage) WriteLCOV(w io.Writer) error {
+	out := bufio.NewWriter(w)
+	lines := c.Lines()
+
+	fmt.Fprintf(out, "TN:\n")
+	fmt.Fprintf(out, "SF:%s\n", c.file)
+
+	found, hit := 0, 0
+	for _, line := range lines {
+		for i, b := range line.Branches {
+			taken, notTaken := "-", "-"
+			if b.Evaluated {
+				taken = fmt.Sprint(b.Taken)
+				notTaken = fmt.Sprint(b.NotTaken)
+			}
+			fmt.Fprintf(out, "BRDA:%d,%d,0,%s\n", line.Number, i, taken)
+			fmt.Fprintf(out, "BRDA:%d,%d,1,%s\n", line.Number, i, notTaken)
+
+			found += 2
+			if b.Taken > 0 {
+				hit++
+			}
+			if b.NotTaken > 0 {
+				hit++
+			}
+		}
+	}
+	fmt.Fprintf(out, "BRF:%d\n", found)
+	fmt.Fprintf(out, "BRH:%d\n", hit)
+
+	found, hit = 0, 0
+	for _, line := range lines {
+		if !line.Executable() {
+			continue
+		}
+		fmt.Fprintf(out, "DA:%d,%d\n", line.Number, line.Hits)
+
+		found++
+		if line.Hits > 0 {
+			hit++
+		}
+	}
+	fmt.Fprintf(out, "LF:%d\n", found)
+	fmt.Fprintf(out, "LH:%d\n", hit)
+	fmt.Fprintf(out, "end_of_record\n")
+
+	return out.Flush()
+}
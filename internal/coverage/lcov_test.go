@@ -0,0 +1,49 @@
+package coverage
+
+import (
+	"bytes"
+	"context"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/runtime"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// TestWriteLCOV runs a script with one branch taken and one not taken and
+// compares the report with testdata/branch.lcov.
+func TestWriteLCOV(t *testing.T) {
+	source, err := ioutil.ReadFile(filepath.Join("testdata", "branch.lox"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := ioutil.ReadFile(filepath.Join("testdata", "branch.lcov"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lexer := lexing.NewLexer(string(source))
+	lexer.ScanTokens()
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	statements := parser.Parse()
+	if len(lexer.Errors) != 0 || len(parser.Errors) != 0 {
+		t.Fatalf("invalid program: %v %v", lexer.Errors, parser.Errors)
+	}
+
+	c := NewCoverage("branch.lox", lexer.Lines, statements)
+	interpreter := runtime.NewInterpreter()
+	interpreter.SetOutput(ioutil.Discard)
+	interpreter.SetHook(c)
+	if err := interpreter.Interpret(context.Background(), statements); err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := c.WriteLCOV(&out); err != nil {
+		t.Fatalf("WriteLCOV: %v", err)
+	}
+	if got := out.String(); got != string(want) {
+		t.Errorf("WriteLCOV =\n%s\nwant\n%s", got, want)
+	}
+}
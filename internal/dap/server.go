@@ -0,0 +1,643 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/debugger"
+	"github.com/paw1a/golox/internal/framing"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"io"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const threadID = 1
+
+// Server is a debug adapter running a single Lox program per session.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	writeMu sync.Mutex
+	seq     int
+
+	program    string
+	statements []ast.Stmt
+	debugger   *debugger.Debugger
+	lines      map[int]bool
+	functions  []string
+
+	stateMu  sync.Mutex
+	paused   bool
+	running  bool
+	commands chan func()
+	resume   chan debugger.Action
+	finished chan struct{}
+
+	// handles maps variablesReference values to environments, arrays and maps,
+	// it is only touched on the interpreter goroutine and is reset on
+	// every stop.
+	handles []interface{}
+}
+
+// Serve runs a debug adapter over the streams until the client
+// disconnects or the input is closed.
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Run()
+}
+
+// ListenAndServe accepts a single client on a loopback address and runs
+// a debug adapter over the connection.
+func ListenAndServe(address string) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if host != "localhost" {
+		if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+			return fmt.Errorf("refusing to listen on non-loopback address %s", address)
+		}
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	conn, err := listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return Serve(conn, conn)
+}
+
+func (s *Server) Run() error {
+	for {
+		body, err := framing.ReadMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			s.terminate()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			continue
+		}
+
+		if !s.handle(req) {
+			return nil
+		}
+	}
+}
+
+// handle dispatches a request and returns false once the session is over.
+func (s *Server) handle(req request) bool {
+	var body interface{}
+	var err error
+
+	switch req.Command {
+	case "initialize":
+		body = map[string]interface{}{
+			"supportsConfigurationDoneRequest": true,
+			"supportsFunctionBreakpoints":      true,
+			"supportsEvaluateForHovers":        true,
+			"supportsTerminateRequest":         true,
+			"supportTerminateDebuggee":         true,
+		}
+		s.reply(req, body, nil)
+		s.sendEvent("initialized", nil)
+		return true
+	case "launch":
+		var args LaunchArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			err = s.launch(args)
+		}
+	case "setBreakpoints":
+		var args SetBreakpointsArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body = s.setBreakpoints(args)
+		}
+	case "setFunctionBreakpoints":
+		var args SetFunctionBreakpointsArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body = s.setFunctionBreakpoints(args)
+		}
+	case "setExceptionBreakpoints":
+		body = map[string]interface{}{"breakpoints": []Breakpoint{}}
+	case "configurationDone":
+		err = s.start()
+	case "threads":
+		body = map[string]interface{}{"threads": []Thread{{ID: threadID, Name: "main"}}}
+	case "stackTrace":
+		var args StackTraceArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.stackTrace(args)
+		}
+	case "scopes":
+		var args ScopesArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.scopes(args)
+		}
+	case "variables":
+		var args VariablesArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.variables(args)
+		}
+	case "evaluate":
+		var args EvaluateArguments
+		if err = json.Unmarshal(req.Arguments, &args); err == nil {
+			body, err = s.evaluate(args)
+		}
+	case "continue":
+		err = s.resumeWith(debugger.Continue)
+		body = map[string]interface{}{"allThreadsContinued": true}
+	case "next":
+		err = s.resumeWith(debugger.StepOver)
+	case "stepIn":
+		err = s.resumeWith(debugger.StepIn)
+	case "stepOut":
+		err = s.resumeWith(debugger.StepOut)
+	case "pause":
+		if s.debugger != nil {
+			s.debugger.Pause()
+		}
+	case "terminate":
+		s.terminate()
+	case "disconnect":
+		s.terminate()
+		s.reply(req, nil, nil)
+		return false
+	default:
+		err = fmt.Errorf("unsupported request %s", req.Command)
+	}
+
+	s.reply(req, body, err)
+	return true
+}
+
+func (s *Server) launch(args LaunchArguments) error {
+	if s.debugger != nil {
+		return fmt.Errorf("program already launched")
+	}
+
+	program, err := filepath.Abs(args.Program)
+	if err != nil {
+		return err
+	}
+	sourceBytes, err := ioutil.ReadFile(program)
+	if err != nil {
+		return fmt.Errorf("can't read program %s: %v", args.Program, err)
+	}
+
+	lexer := lexing.NewLexer(string(sourceBytes))
+	lexer.ScanTokens()
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	parser.File = program
+	statements := parser.Parse()
+	resolver := resolving.NewResolver()
+	resolver.Resolve(statements)
+
+	errs := append(append(lexer.Errors, parser.Errors...), resolver.Errors()...)
+	if len(errs) != 0 {
+		for _, err := range errs {
+			s.output("stderr", err.Error()+"\n")
+		}
+		return fmt.Errorf("program %s has syntax errors", args.Program)
+	}
+
+	interpreter := runtime.NewInterpreter()
+	interpreter.SetOutput(outputWriter{server: s, category: "stdout"})
+
+	s.program = program
+	s.statements = statements
+	s.debugger = debugger.NewDebugger(interpreter, lexer.Lines, s)
+	s.debugger.StopOnEntry = args.StopOnEntry && !args.NoDebug
+	if !args.NoDebug {
+		for line := range s.lines {
+			s.debugger.SetBreakpoint(line)
+		}
+		for _, name := range s.functions {
+			s.debugger.SetFunctionBreakpoint(name)
+		}
+	}
+	return nil
+}
+
+func (s *Server) start() error {
+	if s.debugger == nil {
+		return fmt.Errorf("no program launched")
+	}
+
+	s.stateMu.Lock()
+	if s.running {
+		s.stateMu.Unlock()
+		return nil
+	}
+	s.running = true
+	s.stateMu.Unlock()
+
+	go func() {
+		defer close(s.finished)
+
+		exitCode := 0
+		err := s.debugger.Run(s.statements)
+		if exit, ok := err.(*runtime.ExitError); ok {
+			exitCode = exit.Code
+		} else if err != nil {
+			s.output("stderr", strings.TrimRight(err.Error(), "\n")+"\n")
+			exitCode = 70
+		}
+
+		s.stateMu.Lock()
+		s.running = false
+		s.stateMu.Unlock()
+
+		s.sendEvent("exited", map[string]interface{}{"exitCode": exitCode})
+		s.sendEvent("terminated", nil)
+	}()
+	return nil
+}
+
+// terminate stops the program, if it is running, and waits for it.
+func (s *Server) terminate() {
+	s.stateMu.Lock()
+	running, paused := s.running, s.paused
+	s.stateMu.Unlock()
+
+	if !running {
+		return
+	}
+	if paused {
+		s.resume <- debugger.Quit
+	} else {
+		s.debugger.Stop()
+	}
+	<-s.finished
+}
+
+func (s *Server) setBreakpoints(args SetBreakpointsArguments) interface{} {
+	sameFile := s.program == "" || samePath(args.Source.Path, s.program)
+
+	s.lines = make(map[int]bool)
+	if s.debugger != nil {
+		s.debugger.ClearBreakpoints()
+	}
+
+	breakpoints := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		if !sameFile {
+			breakpoints = append(breakpoints, Breakpoint{
+				Verified: false,
+				Line:     bp.Line,
+				Message:  "only breakpoints in the launched program are supported",
+			})
+			continue
+		}
+
+		s.lines[bp.Line] = true
+		if s.debugger != nil {
+			s.debugger.SetBreakpoint(bp.Line)
+		}
+		breakpoints = append(breakpoints, Breakpoint{
+			Verified: true,
+			Line:     bp.Line,
+			Source:   &args.Source,
+		})
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+func (s *Server) setFunctionBreakpoints(args SetFunctionBreakpointsArguments) interface{} {
+	s.functions = nil
+	if s.debugger != nil {
+		s.debugger.ClearFunctionBreakpoints()
+	}
+
+	breakpoints := make([]Breakpoint, 0, len(args.Breakpoints))
+	for _, bp := range args.Breakpoints {
+		s.functions = append(s.functions, bp.Name)
+		if s.debugger != nil {
+			s.debugger.SetFunctionBreakpoint(bp.Name)
+		}
+		breakpoints = append(breakpoints, Breakpoint{Verified: true})
+	}
+
+	return map[string]interface{}{"breakpoints": breakpoints}
+}
+
+// Stopped implements debugger.Frontend. While the program is stopped the
+// interpreter goroutine serves the inspection requests sent to it and
+// waits for a request resuming the execution.
+func (s *Server) Stopped(d *debugger.Debugger, reason debugger.StopReason) debugger.Action {
+	s.handles = s.handles[:0]
+
+	s.stateMu.Lock()
+	s.paused = true
+	s.stateMu.Unlock()
+
+	s.sendEvent("stopped", map[string]interface{}{
+		"reason":            reason.String(),
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+	})
+
+	for {
+		select {
+		case command := <-s.commands:
+			command()
+		case action := <-s.resume:
+			s.stateMu.Lock()
+			s.paused = false
+			s.stateMu.Unlock()
+			return action
+		}
+	}
+}
+
+func (s *Server) resumeWith(action debugger.Action) error {
+	if !s.isPaused() {
+		return fmt.Errorf("program is not paused")
+	}
+	s.resume <- action
+	return nil
+}
+
+func (s *Server) isPaused() bool {
+	s.stateMu.Lock()
+	defer s.stateMu.Unlock()
+	return s.paused
+}
+
+// inspect runs the function on the paused interpreter goroutine.
+func (s *Server) inspect(function func()) error {
+	if !s.isPaused() {
+		return fmt.Errorf("program is not paused")
+	}
+
+	done := make(chan struct{})
+	s.commands <- func() {
+		defer close(done)
+		function()
+	}
+	<-done
+	return nil
+}
+
+func (s *Server) stackTrace(args StackTraceArguments) (interface{}, error) {
+	var frames []StackFrame
+	err := s.inspect(func() {
+		for index, frame := range s.debugger.Stack() {
+			frames = append(frames, StackFrame{
+				ID:     index,
+				Name:   frame.Function,
+				Source: &Source{Name: filepath.Base(s.program), Path: s.program},
+				Line:   frame.Line,
+				Column: 1,
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	total := len(frames)
+	if args.StartFrame > 0 && args.StartFrame < len(frames) {
+		frames = frames[args.StartFrame:]
+	} else if args.StartFrame >= len(frames) {
+		frames = nil
+	}
+	if args.Levels > 0 && args.Levels < len(frames) {
+		frames = frames[:args.Levels]
+	}
+	if frames == nil {
+		frames = []StackFrame{}
+	}
+
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": total}, nil
+}
+
+func (s *Server) scopes(args ScopesArguments) (interface{}, error) {
+	scopes := make([]Scope, 0)
+	err := s.inspect(func() {
+		for _, scope := range s.debugger.Scopes(args.FrameID) {
+			hint := "locals"
+			if scope.Name == "Globals" {
+				hint = ""
+			}
+			scopes = append(scopes, Scope{
+				Name:               scope.Name,
+				PresentationHint:   hint,
+				VariablesReference: s.reference(scope.Env),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"scopes": scopes}, nil
+}
+
+func (s *Server) variables(args VariablesArguments) (interface{}, error) {
+	variables := make([]Variable, 0)
+	err := s.inspect(func() {
+		index := args.VariablesReference - 1
+		if index < 0 || index >= len(s.handles) {
+			return
+		}
+
+		switch s.handles[index].(type) {
+		case *runtime.Environment:
+			for _, variable := range debugger.Variables(s.handles[index].(*runtime.Environment)) {
+				variables = append(variables, s.variable(variable.Name, variable.Value))
+			}
+		case *runtime.Array:
+			for i, element := range s.handles[index].(*runtime.Array).Elements {
+				variables = append(variables, s.variable(fmt.Sprintf("[%d]", i), element))
+			}
+		case map[string]interface{}:
+			entries := s.handles[index].(map[string]interface{})
+			keys := make([]string, 0, len(entries))
+			for key := range entries {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				variables = append(variables, s.variable(strconv.Quote(key), entries[key]))
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{"variables": variables}, nil
+}
+
+func (s *Server) evaluate(args EvaluateArguments) (interface{}, error) {
+	frame := 0
+	if args.FrameID != nil {
+		frame = *args.FrameID
+	}
+
+	var result Variable
+	var evalErr error
+	err := s.inspect(func() {
+		value, err := s.debugger.Evaluate(frame, args.Expression)
+		if err != nil {
+			evalErr = err
+			return
+		}
+		result = s.variable(args.Expression, value)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if evalErr != nil {
+		return nil, fmt.Errorf("%s", strings.TrimRight(evalErr.Error(), "\n"))
+	}
+
+	return map[string]interface{}{
+		"result":             result.Value,
+		"type":               result.Type,
+		"variablesReference": result.VariablesReference,
+	}, nil
+}
+
+func (s *Server) variable(name string, value interface{}) Variable {
+	variable := Variable{
+		Name:  name,
+		Value: runtime.Repr(value),
+		Type:  typeName(value),
+	}
+
+	switch value.(type) {
+	case *runtime.Array:
+		if len(value.(*runtime.Array).Elements) > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	case map[string]interface{}:
+		if len(value.(map[string]interface{})) > 0 {
+			variable.VariablesReference = s.reference(value)
+		}
+	}
+	return variable
+}
+
+func (s *Server) reference(value interface{}) int {
+	s.handles = append(s.handles, value)
+	return len(s.handles)
+}
+
+func typeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *runtime.Array:
+		return "array"
+	case map[string]interface{}:
+		return "map"
+	case runtime.Caller:
+		return "function"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func samePath(a string, b string) bool {
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+type outputWriter struct {
+	server   *Server
+	category string
+}
+
+func (w outputWriter) Write(p []byte) (int, error) {
+	w.server.output(w.category, string(p))
+	return len(p), nil
+}
+
+func (s *Server) output(category string, text string) {
+	s.sendEvent("output", map[string]interface{}{
+		"category": category,
+		"output":   text,
+	})
+}
+
+func (s *Server) reply(req request, body interface{}, err error) {
+	resp := response{
+		message:    message{Type: "response"},
+		RequestSeq: req.Seq,
+		Success:    err == nil,
+		Command:    req.Command,
+		Body:       body,
+	}
+	if err != nil {
+		resp.Message = err.Error()
+		resp.Body = map[string]interface{}{
+			"error": map[string]interface{}{"id": 1, "format": err.Error()},
+		}
+	}
+	s.write(&resp, &resp.message)
+}
+
+func (s *Server) sendEvent(name string, body interface{}) {
+	ev := event{
+		message: message{Type: "event"},
+		Event:   name,
+		Body:    body,
+	}
+	s.write(&ev, &ev.message)
+}
+
+// write assigns the next sequence number to the message and sends it.
+func (s *Server) write(value interface{}, header *message) {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+
+	s.seq++
+	header.Seq = s.seq
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		return
+	}
+	framing.WriteMessage(s.out, body)
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:       bufio.NewReader(in),
+		out:      out,
+		lines:    make(map[int]bool),
+		commands: make(chan func()),
+		resume:   make(chan debugger.Action),
+		finished: make(chan struct{}),
+	}
+}
@@ -0,0 +1,368 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/paw1a/golox/internal/framing"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// client drives a server over a pair of pipes, the way an editor does
+// over the standard streams of the adapter process. The messages sent by
+// the server are read on a goroutine so that the server never blocks on
+// an event the test is not waiting for yet.
+type client struct {
+	t        *testing.T
+	in       *io.PipeWriter
+	messages chan []byte
+	seq      int
+	done     chan error
+	output   string
+}
+
+// received is any message sent by the server.
+type received struct {
+	Type       string          `json:"type"`
+	Event      string          `json:"event"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+}
+
+func newClient(t *testing.T) *client {
+	inReader, inWriter := io.Pipe()
+	outReader, outWriter := io.Pipe()
+
+	c := &client{t: t, in: inWriter, messages: make(chan []byte, 64), done: make(chan error, 1)}
+	go func() {
+		c.done <- NewServer(inReader, outWriter).Run()
+		outWriter.Close()
+	}()
+	go func() {
+		defer close(c.messages)
+		out := bufio.NewReader(outReader)
+		for {
+			body, err := framing.ReadMessage(out)
+			if err != nil {
+				return
+			}
+			c.messages <- body
+		}
+	}()
+	return c
+}
+
+func (c *client) send(command string, arguments interface{}) int {
+	c.seq++
+	body, err := json.Marshal(map[string]interface{}{
+		"seq": c.seq, "type": "request", "command": command, "arguments": arguments,
+	})
+	if err != nil {
+		c.t.Fatalf("marshal %s: %v", command, err)
+	}
+	if err := framing.WriteMessage(c.in, body); err != nil {
+		c.t.Fatalf("send %s: %v", command, err)
+	}
+	return c.seq
+}
+
+// next reads messages until one matches, collecting the program output
+// sent in between.
+func (c *client) next(match func(received) bool) received {
+	for {
+		var body []byte
+		select {
+		case message, ok := <-c.messages:
+			if !ok {
+				c.t.Fatal("server closed the output")
+			}
+			body = message
+		case <-time.After(5 * time.Second):
+			c.t.Fatal("timed out waiting for a message")
+		}
+
+		var message received
+		if err := json.Unmarshal(body, &message); err != nil {
+			c.t.Fatalf("invalid message %s: %v", body, err)
+		}
+		if match(message) {
+			return message
+		}
+		if message.Event == "output" {
+			var output struct {
+				Output string `json:"output"`
+			}
+			json.Unmarshal(message.Body, &output)
+			c.output += output.Output
+		}
+	}
+}
+
+// request sends a request and decodes the body of its successful response.
+func (c *client) request(command string, arguments interface{}, body interface{}) {
+	seq := c.send(command, arguments)
+	response := c.next(func(m received) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if !response.Success || response.Command != command {
+		c.t.Fatalf("%s failed: %s", command, response.Message)
+	}
+	if body != nil {
+		if err := json.Unmarshal(response.Body, body); err != nil {
+			c.t.Fatalf("invalid %s body %s: %v", command, response.Body, err)
+		}
+	}
+}
+
+func (c *client) event(name string, body interface{}) {
+	message := c.next(func(m received) bool {
+		return m.Type == "event" && m.Event == name
+	})
+	if body != nil {
+		if err := json.Unmarshal(message.Body, body); err != nil {
+			c.t.Fatalf("invalid %s event %s: %v", name, message.Body, err)
+		}
+	}
+}
+
+// wait waits for the server to return from Run.
+func (c *client) wait() error {
+	select {
+	case err := <-c.done:
+		return err
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("server did not stop")
+		return nil
+	}
+}
+
+func writeProgram(t *testing.T, source string) string {
+	program := filepath.Join(t.TempDir(), "main.lox")
+	if err := ioutil.WriteFile(program, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+const testProgram = `fun greet(name) {
+  var items = [name, 2];
+  print name;
+}
+greet("lox");
+print "done";
+`
+
+func TestSession(t *testing.T) {
+	program := writeProgram(t, testProgram)
+	c := newClient(t)
+
+	var capabilities map[string]interface{}
+	c.request("initialize", map[string]interface{}{"adapterID": "golox"}, &capabilities)
+	if capabilities["supportsConfigurationDoneRequest"] != true {
+		t.Errorf("capabilities = %v", capabilities)
+	}
+	c.event("initialized", nil)
+
+	var breakpoints struct {
+		Breakpoints []Breakpoint `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 3}},
+	}, &breakpoints)
+	if len(breakpoints.Breakpoints) != 1 || !breakpoints.Breakpoints[0].Verified || breakpoints.Breakpoints[0].Line != 3 {
+		t.Errorf("breakpoints = %+v", breakpoints.Breakpoints)
+	}
+
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var stopped struct {
+		Reason   string `json:"reason"`
+		ThreadID int    `json:"threadId"`
+	}
+	c.event("stopped", &stopped)
+	if stopped.Reason != "breakpoint" || stopped.ThreadID != threadID {
+		t.Errorf("stopped = %+v", stopped)
+	}
+
+	var trace struct {
+		StackFrames []StackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", StackTraceArguments{ThreadID: threadID}, &trace)
+	if len(trace.StackFrames) != 2 || trace.StackFrames[0].Name != "greet" || trace.StackFrames[0].Line != 3 {
+		t.Fatalf("stack frames = %+v", trace.StackFrames)
+	}
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.request("scopes", ScopesArguments{FrameID: 0}, &scopes)
+	if len(scopes.Scopes) == 0 || scopes.Scopes[0].Name != "Locals" {
+		t.Fatalf("scopes = %+v", scopes.Scopes)
+	}
+
+	var variables struct {
+		Variables []Variable `json:"variables"`
+	}
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &variables)
+	if len(variables.Variables) != 1 {
+		t.Fatalf("variables = %+v", variables.Variables)
+	}
+	items := variables.Variables[0]
+	if items.Name != "items" || items.Value != `["lox", 2]` || items.Type != "array" || items.VariablesReference == 0 {
+		t.Fatalf("items = %+v", items)
+	}
+
+	c.request("variables", VariablesArguments{VariablesReference: items.VariablesReference}, &variables)
+	want := []Variable{
+		{Name: "[0]", Value: `"lox"`, Type: "string"},
+		{Name: "[1]", Value: "2", Type: "number"},
+	}
+	if len(variables.Variables) != len(want) {
+		t.Fatalf("elements = %+v", variables.Variables)
+	}
+	for i, variable := range variables.Variables {
+		if variable != want[i] {
+			t.Errorf("element %d = %+v, want %+v", i, variable, want[i])
+		}
+	}
+
+	c.request("continue", map[string]interface{}{"threadId": threadID}, nil)
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code = %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+	if c.output != "lox\ndone\n" {
+		t.Errorf("output = %q, want %q", c.output, "lox\ndone\n")
+	}
+
+	c.request("disconnect", nil, nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func TestDisconnectWhileStopped(t *testing.T) {
+	program := writeProgram(t, testProgram)
+	c := newClient(t)
+
+	c.request("initialize", nil, nil)
+	c.request("launch", LaunchArguments{Program: program, StopOnEntry: true}, nil)
+	c.request("configurationDone", nil, nil)
+
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	c.event("stopped", &stopped)
+	if stopped.Reason != "entry" {
+		t.Errorf("stopped reason = %q, want entry", stopped.Reason)
+	}
+
+	c.request("disconnect", map[string]interface{}{"terminateDebuggee": true}, nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+	if c.output != "" {
+		t.Errorf("output = %q, want none", c.output)
+	}
+}
+
+func TestLaunchErrors(t *testing.T) {
+	c := newClient(t)
+	c.request("initialize", nil, nil)
+
+	seq := c.send("launch", LaunchArguments{Program: writeProgram(t, "print ;\n")})
+	response := c.next(func(m received) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if response.Success || response.Message == "" {
+		t.Errorf("launch of an invalid program = %+v", response)
+	}
+
+	seq = c.send("configurationDone", nil)
+	response = c.next(func(m received) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if response.Success {
+		t.Errorf("configurationDone without a program succeeded")
+	}
+
+	c.in.Close()
+	if err := c.wait(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
+
+func TestMapVariables(t *testing.T) {
+	program := writeProgram(t, "var m = {\"b\": [1], \"a\": 1, \"e\": {}};\nprint len(m);\n")
+	c := newClient(t)
+
+	c.request("initialize", nil, nil)
+	c.request("setBreakpoints", SetBreakpointsArguments{
+		Source:      Source{Path: program},
+		Breakpoints: []SourceBreakpoint{{Line: 2}},
+	}, nil)
+	c.request("launch", LaunchArguments{Program: program}, nil)
+	c.request("configurationDone", nil, nil)
+	c.event("stopped", nil)
+
+	var scopes struct {
+		Scopes []Scope `json:"scopes"`
+	}
+	c.request("scopes", ScopesArguments{FrameID: 0}, &scopes)
+	if len(scopes.Scopes) != 1 {
+		t.Fatalf("scopes = %+v", scopes.Scopes)
+	}
+
+	var variables struct {
+		Variables []Variable `json:"variables"`
+	}
+	c.request("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &variables)
+	if len(variables.Variables) != 2 || variables.Variables[0].Name != "args" {
+		t.Fatalf("globals = %+v", variables.Variables)
+	}
+	m := variables.Variables[1]
+	if m.Name != "m" || m.Type != "map" || m.Value != `{"a": 1, "b": [1], "e": {}}` || m.VariablesReference == 0 {
+		t.Fatalf("m = %+v", m)
+	}
+
+	c.request("variables", VariablesArguments{VariablesReference: m.VariablesReference}, &variables)
+	want := []Variable{
+		{Name: `"a"`, Value: "1", Type: "number"},
+		{Name: `"b"`, Value: "[1]", Type: "array"},
+		{Name: `"e"`, Value: "{}", Type: "map"},
+	}
+	if len(variables.Variables) != len(want) {
+		t.Fatalf("entries = %+v", variables.Variables)
+	}
+	for i, variable := range variables.Variables {
+		if variable.Name == `"b"` && variable.VariablesReference == 0 {
+			t.Errorf("entry %s can't be expanded", variable.Name)
+		}
+		variable.VariablesReference = 0
+		if variable != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, variable, want[i])
+		}
+	}
+
+	c.request("continue", nil, nil)
+	c.event("terminated", nil)
+	if c.output != "3\n" {
+		t.Errorf("output = %q, want %q", c.output, "3\n")
+	}
+	c.request("disconnect", nil, nil)
+	if err := c.wait(); err != nil {
+		t.Errorf("Run = %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/paw1a/golox/internal/runtime"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const consoleHelp = `commands:
+  b, break <line|function>   set a breakpoint
+  d, delete <line|function>  remove a breakpoint
+  bl, breakpoints            list breakpoints
+  s, step                    step into the next statement
+  n, next                    step over function calls
+  f, finish                  run until the current function returns
+  c, continue                run until the next breakpoint
+  bt, stack                  print the call stack
+  fr, frame <n>              select the frame for locals and print
+  l, locals                  print variables of the selected frame
+  p, print <expr>            evaluate an expression in the selected frame
+  w, watch <expr>            evaluate an expression on every stop
+  unwatch <n>                remove a watch expression
+  list                       print source around the current line
+  q, quit                    stop the program
+  h, help                    print this help`
+
+// Console is a Frontend driven by commands typed on a terminal.
+type Console struct {
+	in    *bufio.Scanner
+	out   io.Writer
+	frame int
+}
+
+func (c *Console) Stopped(d *Debugger, reason StopReason) Action {
+	c.frame = 0
+	line := d.Line()
+	fmt.Fprintf(c.out, "stopped at line %d (%s) in %s\n", line, reason, d.Stack()[0].Function)
+	fmt.Fprintf(c.out, "%5d | %s\n", line, d.SourceLine(line))
+	c.printWatches(d)
+
+	for {
+		fmt.Fprint(c.out, "(golox) ")
+		if !c.in.Scan() {
+			return Quit
+		}
+
+		command, argument := splitCommand(c.in.Text())
+		switch command {
+		case "":
+			continue
+		case "s", "step":
+			return StepIn
+		case "n", "next":
+			return StepOver
+		case "f", "finish":
+			return StepOut
+		case "c", "continue":
+			return Continue
+		case "q", "quit":
+			return Quit
+		case "b", "break":
+			c.setBreakpoint(d, argument)
+		case "d", "delete":
+			c.deleteBreakpoint(d, argument)
+		case "bl", "breakpoints":
+			c.printBreakpoints(d)
+		case "bt", "stack":
+			c.printStack(d)
+		case "fr", "frame":
+			c.selectFrame(d, argument)
+		case "l", "locals":
+			c.printLocals(d)
+		case "p", "print":
+			c.printExpression(d, argument)
+		case "w", "watch":
+			if argument == "" {
+				fmt.Fprintln(c.out, "watch expects an expression")
+				continue
+			}
+			d.Watches = append(d.Watches, argument)
+			c.printWatches(d)
+		case "unwatch":
+			c.unwatch(d, argument)
+		case "list":
+			c.printSource(d)
+		case "h", "help":
+			fmt.Fprintln(c.out, consoleHelp)
+		default:
+			fmt.Fprintf(c.out, "unknown command %q, type 'help' for a list of commands\n", command)
+		}
+	}
+}
+
+func splitCommand(input string) (string, string) {
+	input = strings.TrimSpace(input)
+	if index := strings.IndexAny(input, " \t"); index >= 0 {
+		return input[:index], strings.TrimSpace(input[index+1:])
+	}
+	return input, ""
+}
+
+func (c *Console) setBreakpoint(d *Debugger, argument string) {
+	if argument == "" {
+		fmt.Fprintln(c.out, "break expects a line number or a function name")
+		return
+	}
+
+	if line, err := strconv.Atoi(argument); err == nil {
+		if line < 1 || line > d.LineCount() {
+			fmt.Fprintf(c.out, "line %d is out of the source\n", line)
+			return
+		}
+		d.SetBreakpoint(line)
+		fmt.Fprintf(c.out, "breakpoint at line %d\n", line)
+		return
+	}
+
+	d.SetFunctionBreakpoint(argument)
+	fmt.Fprintf(c.out, "breakpoint at function %s\n", argument)
+}
+
+func (c *Console) deleteBreakpoint(d *Debugger, argument string) {
+	if line, err := strconv.Atoi(argument); err == nil {
+		d.ClearBreakpoint(line)
+	} else {
+		d.ClearFunctionBreakpoint(argument)
+	}
+}
+
+func (c *Console) printBreakpoints(d *Debugger) {
+	for _, line := range d.Breakpoints() {
+		fmt.Fprintf(c.out, "line %d | %s\n", line, strings.TrimSpace(d.SourceLine(line)))
+	}
+	for _, name := range d.FunctionBreakpoints() {
+		fmt.Fprintf(c.out, "function %s\n", name)
+	}
+}
+
+func (c *Console) printStack(d *Debugger) {
+	for index, frame := range d.Stack() {
+		marker := " "
+		if index == c.frame {
+			marker = "*"
+		}
+		fmt.Fprintf(c.out, "%s #%d %s at line %d\n", marker, index, frame.Function, frame.Line)
+	}
+}
+
+func (c *Console) selectFrame(d *Debugger, argument string) {
+	frame, err := strconv.Atoi(argument)
+	if err != nil || frame < 0 || frame >= len(d.Stack()) {
+		fmt.Fprintf(c.out, "invalid frame %q\n", argument)
+		return
+	}
+	c.frame = frame
+	c.printStack(d)
+}
+
+func (c *Console) printLocals(d *Debugger) {
+	for _, scope := range d.Scopes(c.frame) {
+		if len(scope.Variables) == 0 || scope.Name == "Globals" && c.frame != len(d.Stack())-1 {
+			continue
+		}
+		fmt.Fprintf(c.out, "%s:\n", scope.Name)
+		for _, variable := range scope.Variables {
+			fmt.Fprintf(c.out, "  %s = %s\n", variable.Name, runtime.Repr(variable.Value))
+		}
+	}
+}
+
+func (c *Console) printExpression(d *Debugger, source string) {
+	value, err := d.Evaluate(c.frame, source)
+	if err != nil {
+		fmt.Fprintln(c.out, strings.TrimRight(err.Error(), "\n"))
+		return
+	}
+	fmt.Fprintln(c.out, runtime.Repr(value))
+}
+
+func (c *Console) printWatches(d *Debugger) {
+	for index, watch := range d.Watches {
+		value, err := d.Evaluate(c.frame, watch)
+		if err != nil {
+			fmt.Fprintf(c.out, "watch #%d %s: <%s>\n", index, watch, strings.TrimRight(err.Error(), "\n"))
+			continue
+		}
+		fmt.Fprintf(c.out, "watch #%d %s = %s\n", index, watch, runtime.Repr(value))
+	}
+}
+
+func (c *Console) unwatch(d *Debugger, argument string) {
+	index, err := strconv.Atoi(argument)
+	if err != nil || index < 0 || index >= len(d.Watches) {
+		fmt.Fprintf(c.out, "invalid watch %q\n", argument)
+		return
+	}
+	d.Watches = append(d.Watches[:index], d.Watches[index+1:]...)
+}
+
+func (c *Console) printSource(d *Debugger) {
+	current := d.Line()
+	for line := current - 5; line <= current+5; line++ {
+		if line < 1 || line > d.LineCount() {
+			continue
+		}
+		marker := " "
+		if line == current {
+			marker = ">"
+		}
+		fmt.Fprintf(c.out, "%s%4d | %s\n", marker, line, d.SourceLine(line))
+	}
+}
+
+func NewConsole(in io.Reader, out io.Writer) *Console {
+	return &Console{
+		in:  bufio.NewScanner(in),
+		out: out,
+	}
+}
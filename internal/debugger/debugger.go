@@ -0,0 +1,352 @@
+package debugger
+
+import (
+	"errors"
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/runtime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+type Action int
+
+const (
+	Continue Action = iota
+	StepIn
+	StepOver
+	StepOut
+	Quit
+)
+
+type StopReason int
+
+const (
+	Entry StopReason = iota
+	Step
+	Breakpoint
+	FunctionBreakpoint
+	Pause
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case Entry:
+		return "entry"
+	case Breakpoint:
+		return "breakpoint"
+	case FunctionBreakpoint:
+		return "function breakpoint"
+	case Pause:
+		return "pause"
+	}
+	return "step"
+}
+
+// Frontend presents a stopped program to the user and decides how the
+// execution resumes. Stopped is called on the interpreter goroutine, the
+// program stays paused until it returns.
+type Frontend interface {
+	Stopped(d *Debugger, reason StopReason) Action
+}
+
+// Variable is a name visible in some scope of a paused frame.
+type Variable struct {
+	Name  string
+	Value interface{}
+}
+
+// Scope is one level of the environment chain of a frame.
+type Scope struct {
+	Name      string
+	Env       *runtime.Environment
+	Variables []Variable
+}
+
+var errQuit = errors.New("debugger: quit")
+
+// Debugger is a runtime.Hook pausing the program on breakpoints and steps.
+// Breakpoints may be changed and a pause or stop requested from another
+// goroutine while the program runs, everything else must be done from
+// the Frontend while the program is stopped.
+type Debugger struct {
+	interpreter *runtime.Interpreter
+	frontend    Frontend
+	lines       []string
+
+	mu                  sync.Mutex
+	breakpoints         map[int]bool
+	functionBreakpoints map[string]bool
+	pauseRequested      bool
+	stopRequested       bool
+
+	Watches     []string
+	StopOnEntry bool
+
+	action    Action
+	depth     int
+	lastLine  int
+	lastDepth int
+	stmt      ast.Stmt
+}
+
+// Run executes the program under the debugger, stopping on its first
+// statement. Runtime errors and exit are returned as a *runtime.Error and a
+// *runtime.ExitError, quitting is not an error.
+func (d *Debugger) Run(statements []ast.Stmt) (err error) {
+	d.interpreter.SetHook(d)
+	defer func() {
+		d.interpreter.SetHook(nil)
+		if r := recover(); r != nil {
+			if r == errQuit {
+				err = nil
+				return
+			}
+			switch r.(type) {
+			case *runtime.Error, *runtime.ExitError:
+				err = r.(error)
+				return
+			}
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	d.action = Continue
+	if d.StopOnEntry {
+		d.action = StepIn
+	}
+	d.lastDepth = -1
+	for _, stmt := range statements {
+		d.interpreter.Execute(stmt)
+	}
+	return nil
+}
+
+func (d *Debugger) BeforeStmt(stmt ast.Stmt) {
+	switch stmt.(type) {
+	case ast.BlockStmt:
+		return
+	}
+
+	line := stmt.Span().Start.Line
+	depth := d.interpreter.Depth()
+	enteredLine := line != d.lastLine || depth != d.lastDepth
+	enteredCall := depth > d.lastDepth
+	d.lastLine, d.lastDepth = line, depth
+
+	d.mu.Lock()
+	reason, stop := d.stopReason(line, depth, enteredLine, enteredCall)
+	quit := d.stopRequested
+	d.pauseRequested = false
+	d.mu.Unlock()
+
+	if quit {
+		panic(errQuit)
+	}
+	if !stop {
+		return
+	}
+
+	d.stmt = stmt
+	action := d.frontend.Stopped(d, reason)
+	if action == Quit {
+		panic(errQuit)
+	}
+	d.action, d.depth = action, depth
+}
+
+func (d *Debugger) stopReason(line int, depth int, enteredLine bool, enteredCall bool) (StopReason, bool) {
+	switch {
+	case d.action == StepIn && d.stmt == nil:
+		return Entry, true
+	case d.pauseRequested:
+		return Pause, true
+	case d.action == StepIn:
+		return Step, true
+	case d.action == StepOver && depth <= d.depth:
+		return Step, true
+	case d.action == StepOut && depth < d.depth:
+		return Step, true
+	case enteredLine && d.breakpoints[line]:
+		return Breakpoint, true
+	case enteredCall && depth > 0 && d.functionBreakpoints[d.interpreter.CallStack()[0].Function]:
+		return FunctionBreakpoint, true
+	}
+	return Step, false
+}
+
+// Pause stops the running program before its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.pauseRequested = true
+}
+
+// Stop aborts the running program before its next statement.
+func (d *Debugger) Stop() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.stopRequested = true
+}
+
+// Line returns the line the program is paused at.
+func (d *Debugger) Line() int {
+	if d.stmt == nil {
+		return 0
+	}
+	return d.stmt.Span().Start.Line
+}
+
+// SourceLine returns the text of the given source line.
+func (d *Debugger) SourceLine(line int) string {
+	if line < 1 || line > len(d.lines) {
+		return ""
+	}
+	return strings.TrimRight(d.lines[line-1], "\r\n")
+}
+
+func (d *Debugger) LineCount() int {
+	return len(d.lines)
+}
+
+func (d *Debugger) Stack() []runtime.Frame {
+	return d.interpreter.CallStack()
+}
+
+func (d *Debugger) SetBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints[line] = true
+}
+
+func (d *Debugger) ClearBreakpoint(line int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.breakpoints, line)
+}
+
+func (d *Debugger) ClearBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.breakpoints = make(map[int]bool)
+}
+
+func (d *Debugger) SetFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.functionBreakpoints[name] = true
+}
+
+func (d *Debugger) ClearFunctionBreakpoint(name string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.functionBreakpoints, name)
+}
+
+func (d *Debugger) ClearFunctionBreakpoints() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.functionBreakpoints = make(map[string]bool)
+}
+
+// Breakpoints returns the line breakpoints, sorted.
+func (d *Debugger) Breakpoints() []int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	lines := make([]int, 0, len(d.breakpoints))
+	for line := range d.breakpoints {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// FunctionBreakpoints returns the function breakpoints, sorted.
+func (d *Debugger) FunctionBreakpoints() []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	names := make([]string, 0, len(d.functionBreakpoints))
+	for name := range d.functionBreakpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Scopes returns the environment chain of the frame, innermost first.
+// The chain of the top level frame consists of the globals only.
+func (d *Debugger) Scopes(frame int) []Scope {
+	stack := d.interpreter.CallStack()
+	if frame < 0 || frame >= len(stack) {
+		return nil
+	}
+
+	var scopes []Scope
+	globals := d.interpreter.Globals()
+	for env := stack[frame].Env; env != nil; env = env.Enclosing() {
+		name := "Locals"
+		switch {
+		case env == globals:
+			name = "Globals"
+		case len(scopes) > 0:
+			name = fmt.Sprintf("Enclosing #%d", len(scopes))
+		}
+		scopes = append(scopes, Scope{Name: name, Env: env, Variables: Variables(env)})
+	}
+	return scopes
+}
+
+// Variables returns the names defined directly in the environment. Natives
+// are left out of the globals, they are the same in every program.
+func Variables(env *runtime.Environment) []Variable {
+	builtins := runtime.Builtins()
+
+	var variables []Variable
+	for _, name := range env.Names() {
+		value, _ := env.Lookup(name)
+		if native, ok := builtins[name]; ok && env.Enclosing() == nil && value == native {
+			continue
+		}
+		variables = append(variables, Variable{Name: name, Value: value})
+	}
+	return variables
+}
+
+// Evaluate parses the source as an expression and evaluates it in the
+// innermost scope of the frame.
+func (d *Debugger) Evaluate(frame int, source string) (interface{}, error) {
+	stack := d.interpreter.CallStack()
+	if frame < 0 || frame >= len(stack) {
+		return nil, fmt.Errorf("no frame #%d", frame)
+	}
+
+	lexer := lexing.NewLexer(source)
+	lexer.ScanTokens()
+	if len(lexer.Errors) != 0 {
+		return nil, lexer.Errors[0]
+	}
+
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	expr := parser.ParseExpression()
+	if len(parser.Errors) != 0 {
+		return nil, parser.Errors[0]
+	}
+
+	return d.interpreter.EvaluateIn(stack[frame].Env, expr)
+}
+
+func NewDebugger(interpreter *runtime.Interpreter, lines []string, frontend Frontend) *Debugger {
+	return &Debugger{
+		interpreter:         interpreter,
+		frontend:            frontend,
+		lines:               lines,
+		breakpoints:         make(map[int]bool),
+		functionBreakpoints: make(map[string]bool),
+		StopOnEntry:         true,
+	}
+}
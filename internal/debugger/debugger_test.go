@@ -0,0 +1,54 @@
+package debugger
+
+import (
+	"bytes"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/runtime"
+	"strings"
+	"testing"
+)
+
+// frontendFunc adapts a function to the Frontend interface.
+type frontendFunc func(d *Debugger, reason StopReason) Action
+
+func (f frontendFunc) Stopped(d *Debugger, reason StopReason) Action {
+	return f(d, reason)
+}
+
+// Expressions evaluated at a breakpoint are not resolved, so assigning to
+// a constant there is caught by the interpreter.
+func TestEvaluateAssignToConstant(t *testing.T) {
+	lexer := lexing.NewLexer("const LIMIT = 1;\nprint LIMIT;\n")
+	lexer.ScanTokens()
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	statements := parser.Parse()
+	if len(lexer.Errors) != 0 || len(parser.Errors) != 0 {
+		t.Fatalf("invalid program: %v %v", lexer.Errors, parser.Errors)
+	}
+
+	interpreter := runtime.NewInterpreter()
+	var out bytes.Buffer
+	interpreter.SetOutput(&out)
+
+	var assignErr error
+	stops := 0
+	d := NewDebugger(interpreter, lexer.Lines, frontendFunc(func(d *Debugger, reason StopReason) Action {
+		stops++
+		if stops == 1 {
+			return StepOver
+		}
+		_, assignErr = d.Evaluate(0, "LIMIT = 3")
+		return Continue
+	}))
+	if err := d.Run(statements); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if assignErr == nil || !strings.Contains(assignErr.Error(), "cannot assign to constant 'LIMIT'") {
+		t.Errorf("assignment error = %v, want cannot assign to constant 'LIMIT'", assignErr)
+	}
+	if got := out.String(); got != "1\n" {
+		t.Errorf("output = %q, want %q", got, "1\n")
+	}
+}
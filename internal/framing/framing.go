@@ -0,0 +1,48 @@
+package framing
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+)
+
+// ReadMessage reads the body of a message framed by a Content-Length
+// header, as the language server and debug adapter protocols send them.
+// It returns io.EOF only if the stream ended between two messages.
+func ReadMessage(in *bufio.Reader) ([]byte, error) {
+	headers, err := textproto.NewReader(in).ReadMIMEHeader()
+	switch {
+	case errors.Is(err, io.EOF) && len(headers) == 0:
+		return nil, io.EOF
+	case errors.Is(err, io.EOF):
+		return nil, io.ErrUnexpectedEOF
+	case err != nil:
+		return nil, err
+	}
+
+	length, err := strconv.Atoi(headers.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Length header: %v", err)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(in, body); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return body, nil
+}
+
+// WriteMessage writes the body framed by a Content-Length header.
+func WriteMessage(out io.Writer, body []byte) error {
+	if _, err := fmt.Fprintf(out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err := out.Write(body)
+	return err
+}
@@ -0,0 +1,61 @@
+package framing
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	messages := []string{`{"id":1}`, ``, `{"text":"line\r\n\r\nline"}`}
+
+	var buffer bytes.Buffer
+	for _, message := range messages {
+		if err := WriteMessage(&buffer, []byte(message)); err != nil {
+			t.Fatalf("WriteMessage(%q): %v", message, err)
+		}
+	}
+
+	in := bufio.NewReader(&buffer)
+	for _, message := range messages {
+		body, err := ReadMessage(in)
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+		if string(body) != message {
+			t.Errorf("ReadMessage = %q, want %q", body, message)
+		}
+	}
+	if _, err := ReadMessage(in); err != io.EOF {
+		t.Errorf("ReadMessage at the end = %v, want io.EOF", err)
+	}
+}
+
+func TestReadMessageErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  error
+	}{
+		{"truncated body", "Content-Length: 10\r\n\r\n{\"id\"", io.ErrUnexpectedEOF},
+		{"missing body", "Content-Length: 10\r\n\r\n", io.ErrUnexpectedEOF},
+		{"truncated headers", "Content-Length: 10\r\n", io.ErrUnexpectedEOF},
+		{"missing length", "Content-Type: text\r\n\r\n", nil},
+		{"invalid length", "Content-Length: ten\r\n\r\n", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ReadMessage(bufio.NewReader(strings.NewReader(test.input)))
+			switch {
+			case err == nil || errors.Is(err, io.EOF):
+				t.Errorf("ReadMessage error = %v, want a failure", err)
+			case test.want != nil && !errors.Is(err, test.want):
+				t.Errorf("ReadMessage error = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
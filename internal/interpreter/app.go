@@ -2,47 +2,317 @@ package interpreter
 
 import (
 	"bufio"
+	"context"
+	"flag"
 	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/coverage"
+	"github.com/paw1a/golox/internal/dap"
+	"github.com/paw1a/golox/internal/debugger"
 	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/lsp"
 	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/profiler"
+	"github.com/paw1a/golox/internal/resolving"
 	"github.com/paw1a/golox/internal/runtime"
+	"github.com/paw1a/golox/internal/testrunner"
+	"io"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"strings"
 )
 
 var HasError = false
 
-func Run() {
-	if len(os.Args) > 2 {
-		fmt.Printf("usage: golox [source code filename]")
+// exitCode is the status the program asked for by calling exit.
+var exitCode *int
+
+const usage = `usage: golox [flags] [source code filename | -] [arguments...]
+       golox [flags] -e <code> [arguments...]
+       golox debug <source code filename>
+       golox test [-v] [-tap] [-junit file] [dir or file...]
+       golox dap [--listen 127.0.0.1:<port>]
+       golox lsp
+
+flags:
+`
+
+// Run runs the command line and returns the exit status of the process.
+func Run() int {
+	runCommand()
+	if exitCode != nil {
+		return *exitCode
+	}
+	if HasError {
+		return 1
+	}
+	return 0
+}
+
+func runCommand() {
+	if len(os.Args) >= 2 {
+		switch {
+		case os.Args[1] == "lsp" && len(os.Args) == 2:
+			runLanguageServer()
+			return
+		case os.Args[1] == "dap":
+			runDebugAdapter(os.Args[2:])
+			return
+		case os.Args[1] == "debug" && len(os.Args) == 3:
+			runDebugger(os.Args[2])
+			return
+		case os.Args[1] == "test":
+			runTests(os.Args[2:])
+			return
+		}
+	}
+
+	flags := flag.NewFlagSet("golox", flag.ContinueOnError)
+	code := flags.String("e", "", "run the `code` given on the command line instead of a file")
+	profile := flags.String("profile", "", "write a pprof profile of the program to `file`")
+	cover := flags.String("coverage", "", "write an lcov coverage report to `file` and an html one next to it")
+	maxSteps := flags.Int("max-steps", 0, "stop the program after `n` statements")
+	maxDepth := flags.Int("max-depth", 0, "raise a stack overflow error beyond `n` nested calls")
+	maxAlloc := flags.Int("max-alloc", 0, "stop the program once it allocated `n` bytes")
+	timeout := flags.Duration("timeout", 0, "stop the program after the `duration`")
+	sandbox := flags.Bool("sandbox", false, "disable the natives exiting, sleeping, reading input or using files and the terminal")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(os.Args[1:]); err != nil {
+		HasError = true
 		return
 	}
 
-	if len(os.Args) == 2 {
-		runFile(os.Args[1])
-	} else {
-		runPrompt()
+	var options []runtime.Option
+	if *maxSteps > 0 {
+		options = append(options, runtime.WithMaxSteps(*maxSteps))
+	}
+	if *maxDepth > 0 {
+		options = append(options, runtime.WithMaxCallDepth(*maxDepth))
+	}
+	if *maxAlloc > 0 {
+		options = append(options, runtime.WithMaxAllocation(*maxAlloc))
+	}
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if *sandbox {
+		options = append(options, runtime.WithCapabilities())
+	}
+
+	args := flags.Args()
+	switch {
+	case *code != "":
+		options = append(options, runtime.WithArgs(args...))
+		runSource(ctx, "<command line>", *code, *profile, *cover, options)
+	case len(args) == 0:
+		runPrompt(ctx, options)
+	default:
+		source, ok := readSource(args[0])
+		if !ok {
+			HasError = true
+			return
+		}
+		filename := args[0]
+		if filename == "-" {
+			filename = "<stdin>"
+		}
+		options = append(options, runtime.WithArgs(args[1:]...))
+		runSource(ctx, filename, source, *profile, *cover, options)
 	}
 }
 
-func runFile(filename string) {
-	file, err := os.Open(filename)
+// readSource reads the program from the file, or from the standard input
+// if the filename is "-".
+func readSource(filename string) (string, bool) {
+	file := os.Stdin
+	if filename != "-" {
+		var err error
+		file, err = os.Open(filename)
+		if err != nil {
+			fmt.Printf("invalid source code filename: %s\n", filename)
+			return "", false
+		}
+		defer file.Close()
+	}
+
+	sourceBytes, err := ioutil.ReadAll(file)
 	if err != nil {
-		fmt.Printf("invalid source code filename: %s", filename)
+		fmt.Printf("can't read file %s: %v\n", filename, err)
+		return "", false
+	}
+
+	return string(sourceBytes), true
+}
+
+func runSource(ctx context.Context, filename string, source string, profile string, cover string, options []runtime.Option) {
+	statements, lines, ok := parse(filename, source)
+	if !ok {
 		return
 	}
 
-	sourceBytes, err := ioutil.ReadAll(file)
+	var hooks []runtime.Hook
+	var p *profiler.Profiler
+	if profile != "" {
+		p = profiler.NewProfiler(filename)
+		hooks = append(hooks, p)
+	}
+	var c *coverage.Coverage
+	if cover != "" {
+		c = coverage.NewCoverage(filename, lines, statements)
+		hooks = append(hooks, c)
+	}
+
+	switch len(hooks) {
+	case 0:
+		execute(ctx, statements, nil, options...)
+	case 1:
+		execute(ctx, statements, hooks[0], options...)
+	default:
+		execute(ctx, statements, runtime.MultiHook(hooks...), options...)
+	}
+
+	if p != nil {
+		writeReport(profile, "profile", p.WriteProfile)
+	}
+	if c != nil {
+		writeReport(cover, "coverage report", c.WriteLCOV)
+		html := strings.TrimSuffix(cover, filepath.Ext(cover)) + ".html"
+		if html == cover {
+			html += ".html"
+		}
+		writeReport(html, "coverage report", c.WriteHTML)
+	}
+}
+
+func writeReport(filename string, kind string, write func(w io.Writer) error) {
+	file, err := os.Create(filename)
 	if err != nil {
-		fmt.Printf("can't read file %s: %v", filename, err)
+		fmt.Printf("can't create %s %s: %v\n", kind, filename, err)
+		HasError = true
+		return
+	}
+	defer file.Close()
+
+	if err := write(file); err != nil {
+		fmt.Printf("can't write %s %s: %v\n", kind, filename, err)
+		HasError = true
+	}
+}
+
+func runLanguageServer() {
+	if err := lsp.Serve(os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "language server failed: %v\n", err)
+		HasError = true
+	}
+}
+
+func runDebugAdapter(args []string) {
+	var err error
+	switch {
+	case len(args) == 0:
+		err = dap.Serve(os.Stdin, os.Stdout)
+	case len(args) == 2 && (args[0] == "--listen" || args[0] == "-listen"):
+		err = dap.ListenAndServe(args[1])
+	default:
+		fmt.Print(usage)
 		return
 	}
 
-	run(string(sourceBytes))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "debug adapter failed: %v\n", err)
+		HasError = true
+	}
 }
 
-func runPrompt() {
+func runDebugger(filename string) {
+	source, ok := readSource(filename)
+	if !ok {
+		return
+	}
+
+	statements, lines, ok := parse(filename, source)
+	if !ok {
+		return
+	}
+
+	fmt.Println("golox debugger, type 'help' for a list of commands")
+	d := debugger.NewDebugger(runtime.NewInterpreter(), lines, debugger.NewConsole(os.Stdin, os.Stdout))
+	err := d.Run(statements)
+	if exit, ok := err.(*runtime.ExitError); ok {
+		fmt.Printf("program exited with status %d\n", exit.Code)
+		exitCode = &exit.Code
+		return
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		HasError = true
+		return
+	}
+	fmt.Println("program finished")
+}
+
+func runTests(args []string) {
+	flags := flag.NewFlagSet("golox test", flag.ContinueOnError)
+	verbose := flags.Bool("v", false, "show passing tests and their output")
+	tap := flags.Bool("tap", false, "report in the Test Anything Protocol format")
+	junit := flags.String("junit", "", "also write a JUnit XML report to `file`")
+	if err := flags.Parse(args); err != nil {
+		HasError = true
+		return
+	}
+
+	paths := flags.Args()
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	files, err := testrunner.Discover(paths...)
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		HasError = true
+		return
+	}
+	if len(files) == 0 {
+		fmt.Println("no test files found")
+		return
+	}
+
+	suites := make([]testrunner.Suite, len(files))
+	for i, file := range files {
+		suites[i] = testrunner.RunFile(file)
+		if !suites[i].Passed() {
+			HasError = true
+		}
+	}
+
+	if *tap {
+		err = testrunner.WriteTAP(os.Stdout, suites)
+	} else {
+		err = testrunner.WriteText(os.Stdout, suites, *verbose)
+	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		HasError = true
+	}
+
+	if *junit != "" {
+		writeReport(*junit, "junit report", func(w io.Writer) error {
+			return testrunner.WriteJUnit(w, suites)
+		})
+	}
+}
+
+// runPrompt runs every line in a new interpreter with the options, the
+// prompt ends once the context is done.
+func runPrompt(ctx context.Context, options []runtime.Option) {
 	in := bufio.NewReader(os.Stdin)
 
 	for {
@@ -58,11 +328,15 @@ func runPrompt() {
 			line += ";"
 		}
 
-		run(line)
+		if exited := run(ctx, "<stdin>", line, options); exited || ctx.Err() != nil {
+			break
+		}
 	}
 }
 
-func run(source string) {
+// parse reports lexing and parsing errors and returns the program along
+// with its source lines.
+func parse(filename string, source string) ([]ast.Stmt, []string, bool) {
 	lexer := lexing.NewLexer(source)
 	lexer.ScanTokens()
 
@@ -71,34 +345,71 @@ func run(source string) {
 			fmt.Printf("%s\n", err.Error())
 		}
 		HasError = true
-		return
+		return nil, nil, false
 	}
 
-	//for _, token := range lexer.Tokens {
-	//	fmt.Printf("%d %d\n", token.Line, token.Position)
-	//}
-
 	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	parser.File = filename
 	statements := parser.Parse()
 
+	// Warnings go to the standard error so that they don't mix with what
+	// the program prints.
+	for _, warning := range parser.Warnings {
+		fmt.Fprintf(os.Stderr, "%s\n", warning.Error())
+	}
+
 	if len(parser.Errors) != 0 {
 		for _, err := range parser.Errors {
 			fmt.Printf("%s\n", err.Error())
 		}
 		HasError = true
-		return
+		return nil, nil, false
+	}
+
+	resolver := resolving.NewResolver()
+	resolver.Resolve(statements)
+	if errs := resolver.Errors(); len(errs) != 0 {
+		for _, err := range errs {
+			fmt.Printf("%s\n", err.Error())
+		}
+		HasError = true
+		return nil, nil, false
 	}
 
-	inter := runtime.NewInterpreter()
+	return statements, lexer.Lines, true
+}
+
+func run(ctx context.Context, filename string, source string, options []runtime.Option) bool {
+	statements, _, ok := parse(filename, source)
+	if !ok {
+		return false
+	}
+	return execute(ctx, statements, nil, options...)
+}
+
+// execute runs the program and reports whether it called exit.
+func execute(ctx context.Context, statements []ast.Stmt, hook runtime.Hook, options ...runtime.Option) bool {
+	inter := runtime.NewInterpreter(options...)
+	if hook != nil {
+		inter.SetHook(hook)
+	}
 
 	defer errorRecovery()
-	for _, stmt := range statements {
-		inter.Execute(stmt)
+	err := inter.Interpret(ctx, statements)
+	if exit, ok := err.(*runtime.ExitError); ok {
+		exitCode = &exit.Code
+		return true
 	}
+	if err != nil {
+		fmt.Printf("%v\n", err)
+		HasError = true
+	}
+	return false
 }
 
 func errorRecovery() {
 	if err := recover(); err != nil {
 		fmt.Printf("%v\n", err)
+		HasError = true
 	}
 }
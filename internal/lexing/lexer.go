@@ -10,9 +10,11 @@ import (
 type Lexer struct {
 	Tokens []Token
 
-	start   int
-	current int
-	line    int
+	start          int
+	current        int
+	line           int
+	startLine      int
+	startLineStart int
 
 	Errors []error
 
@@ -24,16 +26,22 @@ type Lexer struct {
 func (l *Lexer) ScanTokens() []Token {
 	for !l.isEOF() {
 		l.start = l.current
+		l.startLine = l.line
+		l.startLineStart = l.lineStart
 		l.ScanToken()
 	}
 
-	if l.source[len(l.source)-2] != '\n' {
-		l.Tokens = append(l.Tokens, NewToken(Eof, "", nil, l.line, len(l.source)))
+	if len(l.source) < 2 || l.source[len(l.source)-2] != '\n' {
+		eof := NewToken(Eof, "", nil, l.line, l.current-l.lineStart)
+		eof.Offset = len(l.source)
+		l.Tokens = append(l.Tokens, eof)
 		l.nextLine()
 		return l.Tokens
 	}
 
-	l.Tokens = append(l.Tokens, NewToken(Eof, "", nil, l.line, l.start-l.lineStart+1))
+	eof := NewToken(Eof, "", nil, l.line, l.start-l.lineStart+1)
+	eof.Offset = len(l.source)
+	l.Tokens = append(l.Tokens, eof)
 
 	return l.Tokens
 }
@@ -56,17 +64,63 @@ func (l *Lexer) ScanToken() {
 	case ',':
 		l.addToken(Comma)
 	case '.':
-		l.addToken(Dot)
+		if l.peek() == '.' && l.peekNext() == '.' {
+			l.advance()
+			l.advance()
+			l.addToken(Ellipsis)
+		} else {
+			l.addToken(Dot)
+		}
 	case '-':
-		l.addToken(Minus)
+		if l.peek() == '-' {
+			l.advance()
+			l.addToken(MinusMinus)
+		} else if l.peek() == '=' {
+			l.advance()
+			l.addToken(MinusEqual)
+		} else {
+			l.addToken(Minus)
+		}
 	case '+':
-		l.addToken(Plus)
+		if l.peek() == '+' {
+			l.advance()
+			l.addToken(PlusPlus)
+		} else if l.peek() == '=' {
+			l.advance()
+			l.addToken(PlusEqual)
+		} else {
+			l.addToken(Plus)
+		}
 	case ';':
 		l.addToken(Semicolon)
 	case '*':
-		l.addToken(Star)
+		if l.peek() == '=' {
+			l.advance()
+			l.addToken(StarEqual)
+		} else {
+			l.addToken(Star)
+		}
+	case '%':
+		if l.peek() == '=' {
+			l.advance()
+			l.addToken(PercentEqual)
+		} else {
+			l.addToken(Percent)
+		}
 	case '?':
-		l.addToken(Question)
+		switch l.peek() {
+		case '?':
+			l.advance()
+			l.addToken(QuestionQuestion)
+		case '.':
+			l.advance()
+			l.addToken(QuestionDot)
+		case '[':
+			l.advance()
+			l.addToken(QuestionBracket)
+		default:
+			l.addToken(Question)
+		}
 	case ':':
 		l.addToken(Colon)
 	case '!':
@@ -94,6 +148,9 @@ func (l *Lexer) ScanToken() {
 		if l.peek() == '=' {
 			l.advance()
 			l.addToken(EqualEqual)
+		} else if l.peek() == '>' {
+			l.advance()
+			l.addToken(Arrow)
 		} else {
 			l.addToken(Equal)
 		}
@@ -106,6 +163,9 @@ func (l *Lexer) ScanToken() {
 		} else if l.peek() == '*' {
 			l.advance()
 			l.blockComment()
+		} else if l.peek() == '=' {
+			l.advance()
+			l.addToken(SlashEqual)
 		} else {
 			l.addToken(Slash)
 		}
@@ -127,10 +187,9 @@ func (l *Lexer) ScanToken() {
 
 func (l *Lexer) string() {
 	for !l.isEOF() && l.peek() != '"' {
-		if l.peek() == '\n' {
+		if l.advance() == '\n' {
 			l.nextLine()
 		}
-		l.advance()
 	}
 
 	if l.isEOF() {
@@ -217,6 +276,10 @@ var keywords = map[string]TokenType{
 	"while":    While,
 	"break":    Break,
 	"continue": Continue,
+	"print":    Print,
+	"match":    Match,
+	"case":     Case,
+	"const":    Const,
 }
 
 func (l *Lexer) identifier() {
@@ -239,24 +302,48 @@ func (l *Lexer) addToken(tokenType TokenType) {
 
 func (l *Lexer) addTokenWithLiteral(tokenType TokenType, literal interface{}) {
 	lexeme := l.source[l.start:l.current]
-	position := l.start - l.lineStart
-	l.Tokens = append(l.Tokens, NewToken(tokenType, lexeme, literal, l.line, position))
+	position := l.start - l.startLineStart
+	token := NewToken(tokenType, lexeme, literal, l.startLine, position)
+	token.Offset = l.start
+	l.Tokens = append(l.Tokens, token)
+}
+
+// Error is a lexical error together with the source location it points at.
+type Error struct {
+	Line     int
+	Position int
+	Message  string
+
+	text string
+}
+
+func (e *Error) Error() string {
+	return e.text
 }
 
 func (l *Lexer) error(message string) {
+	position := l.current - l.lineStart - 1
+	if position < 0 {
+		position = 0
+	}
+
 	var buffer bytes.Buffer
 	buffer.WriteString(fmt.Sprintf("[ %d:%d ]: error: %s\n",
-		l.line, l.current-l.lineStart-1, message))
+		l.line, position, message))
 
 	lineStr := strconv.Itoa(l.line)
 	buffer.WriteString(fmt.Sprintf("      %d |         %s\n", l.line, l.source[l.lineStart:l.current]))
 	buffer.WriteString(fmt.Sprintf("      "))
 	buffer.WriteString(strings.Repeat(" ", len(lineStr)))
 	buffer.WriteString(" |         ")
-	fmt.Printf("%d\n", l.current-l.lineStart-1)
-	buffer.WriteString(fmt.Sprintf("%s^\n", strings.Repeat(" ", l.current-l.lineStart-1)))
+	buffer.WriteString(fmt.Sprintf("%s^\n", strings.Repeat(" ", position)))
 
-	l.Errors = append(l.Errors, fmt.Errorf(buffer.String()))
+	l.Errors = append(l.Errors, &Error{
+		Line:     l.line,
+		Position: position,
+		Message:  message,
+		text:     buffer.String(),
+	})
 }
 
 func NewLexer(source string) *Lexer {
@@ -1,6 +1,9 @@
 package lexing
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
+)
 
 type TokenType int
 
@@ -16,12 +19,17 @@ const (
 
 	Comma
 	Dot
+	Ellipsis
 	Minus
 	Plus
 	Semicolon
 	Slash
 	Star
+	Percent
 	Question
+	QuestionQuestion
+	QuestionDot
+	QuestionBracket
 	Colon
 
 	Bang
@@ -32,6 +40,14 @@ const (
 	GreaterEqual
 	Less
 	LessEqual
+	PlusEqual
+	MinusEqual
+	StarEqual
+	SlashEqual
+	PercentEqual
+	PlusPlus
+	MinusMinus
+	Arrow
 
 	Identifier
 	String
@@ -54,6 +70,10 @@ const (
 	While
 	Break
 	Continue
+	Print
+	Match
+	Case
+	Const
 )
 
 type Token struct {
@@ -62,6 +82,41 @@ type Token struct {
 	Literal   interface{}
 	Line      int
 	Position  int
+	Offset    int
+}
+
+// Location is a point in the source: 1-based line, 0-based column
+// and 0-based byte offset from the beginning of the source.
+type Location struct {
+	Line   int
+	Column int
+	Offset int
+}
+
+// Start returns the location of the first byte of the token.
+func (t Token) Start() Location {
+	return Location{
+		Line:   t.Line,
+		Column: t.Position,
+		Offset: t.Offset,
+	}
+}
+
+// End returns the location just past the last byte of the token,
+// taking into account string literals spanning several lines.
+func (t Token) End() Location {
+	end := Location{
+		Line:   t.Line,
+		Column: t.Position + len(t.Lexeme),
+		Offset: t.Offset + len(t.Lexeme),
+	}
+
+	if newlines := strings.Count(t.Lexeme, "\n"); newlines > 0 {
+		end.Line += newlines
+		end.Column = len(t.Lexeme) - strings.LastIndex(t.Lexeme, "\n") - 1
+	}
+
+	return end
 }
 
 func (t *Token) String() string {
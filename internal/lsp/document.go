@@ -0,0 +1,179 @@
+package lsp
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"sort"
+	"strings"
+)
+
+// document is an open text document together with the result of running
+// the lexer, parser and resolver over its current contents.
+type document struct {
+	uri   string
+	text  string
+	lines []string
+
+	tokens      []lexing.Token
+	statements  []ast.Stmt
+	resolver    *resolving.Resolver
+	diagnostics []Diagnostic
+}
+
+func newDocument(uri string, text string) *document {
+	d := &document{
+		uri:   uri,
+		text:  text,
+		lines: strings.Split(text, "\n"),
+	}
+	d.analyze()
+	return d
+}
+
+func (d *document) analyze() {
+	defer func() {
+		if err := recover(); err != nil {
+			d.diagnostics = append(d.diagnostics, Diagnostic{
+				Severity: SeverityError,
+				Source:   "golox",
+				Message:  fmt.Sprintf("internal error: %v", err),
+			})
+		}
+	}()
+
+	lexer := lexing.NewLexer(d.text)
+	d.tokens = lexer.ScanTokens()
+	for _, err := range lexer.Errors {
+		if lexErr, ok := err.(*lexing.Error); ok {
+			start := d.position(lexErr.Line, lexErr.Position)
+			d.addDiagnostic(Range{Start: start, End: Position{
+				Line: start.Line, Character: start.Character + 1,
+			}}, SeverityError, lexErr.Message)
+		}
+	}
+
+	parser := parsing.NewParser(d.tokens, lexer.Lines)
+	d.statements = parser.Parse()
+	for _, err := range parser.Errors {
+		if parseErr, ok := err.(*parsing.Error); ok {
+			d.addDiagnostic(d.tokenRange(parseErr.Token), SeverityError, parseErr.Message)
+		}
+	}
+	for _, err := range parser.Warnings {
+		if parseErr, ok := err.(*parsing.Error); ok {
+			d.addDiagnostic(d.tokenRange(parseErr.Token), SeverityWarning, parseErr.Message)
+		}
+	}
+
+	d.resolver = resolving.NewResolver(builtinNames()...)
+	d.resolver.Resolve(d.statements)
+	for _, diagnostic := range d.resolver.Diagnostics {
+		severity := SeverityError
+		if diagnostic.Severity == resolving.Warning {
+			severity = SeverityWarning
+		}
+		d.addDiagnostic(d.tokenRange(diagnostic.Token), severity, diagnostic.Message)
+	}
+}
+
+func (d *document) addDiagnostic(rng Range, severity DiagnosticSeverity, message string) {
+	d.diagnostics = append(d.diagnostics, Diagnostic{
+		Range:    rng,
+		Severity: severity,
+		Source:   "golox",
+		Message:  message,
+	})
+}
+
+// identifierAt returns the identifier token under the cursor, if any.
+func (d *document) identifierAt(pos Position) (lexing.Token, bool) {
+	line, column := d.location(pos)
+	for _, token := range d.tokens {
+		if token.TokenType != lexing.Identifier || token.Line != line {
+			continue
+		}
+		if column >= token.Position && column <= token.Position+len(token.Lexeme) {
+			return token, true
+		}
+	}
+	return lexing.Token{}, false
+}
+
+func (d *document) tokenRange(token lexing.Token) Range {
+	start := token.Start()
+	end := token.End()
+	return Range{
+		Start: d.position(start.Line, start.Column),
+		End:   d.position(end.Line, end.Column),
+	}
+}
+
+func (d *document) spanRange(span ast.Span) Range {
+	return Range{
+		Start: d.position(span.Start.Line, span.Start.Column),
+		End:   d.position(span.End.Line, span.End.Column),
+	}
+}
+
+// position converts a 1-based line and a byte column into an LSP position,
+// which counts UTF-16 code units.
+func (d *document) position(line int, column int) Position {
+	if line < 1 {
+		return Position{}
+	}
+	if line > len(d.lines) {
+		return Position{Line: line - 1, Character: column}
+	}
+
+	text := d.lines[line-1]
+	if column > len(text) {
+		column = len(text)
+	}
+	return Position{Line: line - 1, Character: utf16Len(text[:column])}
+}
+
+// location converts an LSP position back into a 1-based line and a byte column.
+func (d *document) location(pos Position) (int, int) {
+	if pos.Line < 0 || pos.Line >= len(d.lines) {
+		return pos.Line + 1, pos.Character
+	}
+
+	text := d.lines[pos.Line]
+	units := 0
+	for column, r := range text {
+		if units >= pos.Character {
+			return pos.Line + 1, column
+		}
+		units += utf16RuneLen(r)
+	}
+	return pos.Line + 1, len(text)
+}
+
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16RuneLen(r)
+	}
+	return n
+}
+
+func utf16RuneLen(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// builtinNames returns the names of the natives and of the args global.
+func builtinNames() []string {
+	names := []string{"args"}
+	for name := range runtime.Builtins() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
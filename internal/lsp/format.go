@@ -0,0 +1,90 @@
+package lsp
+
+import (
+	"github.com/paw1a/golox/internal/lexing"
+	"strings"
+)
+
+// format re-indents every line by the nesting depth of brackets, braces and
+// parentheses and strips trailing whitespace. Comments, blank lines and the
+// interior of multi-line string literals and block comments are preserved,
+// so formatting never changes what the program means.
+func (d *document) format(indent string) (string, bool) {
+	lexer := lexing.NewLexer(d.text)
+	tokens := lexer.ScanTokens()
+	if len(lexer.Errors) != 0 {
+		return "", false
+	}
+
+	firstToken := make(map[int]lexing.Token)
+	depthBefore := make(map[int]int)
+	depthAfter := make(map[int]int)
+	verbatim := make(map[int]bool)
+
+	depth := 0
+	for _, token := range tokens {
+		if token.TokenType == lexing.Eof {
+			break
+		}
+		if _, ok := firstToken[token.Line]; !ok {
+			firstToken[token.Line] = token
+			depthBefore[token.Line] = depth
+		}
+		if end := token.End(); end.Line > token.Line {
+			for line := token.Line + 1; line <= end.Line; line++ {
+				verbatim[line] = true
+			}
+		}
+
+		switch token.TokenType {
+		case lexing.LeftBrace, lexing.LeftBracket, lexing.LeftParen, lexing.QuestionBracket:
+			depth++
+		case lexing.RightBrace, lexing.RightBracket, lexing.RightParen:
+			if depth > 0 {
+				depth--
+			}
+		}
+		depthAfter[token.Line] = depth
+	}
+
+	lines := make([]string, len(d.lines))
+	depth = 0
+	for i, line := range d.lines {
+		number := i + 1
+		if verbatim[number] {
+			lines[i] = line
+			continue
+		}
+
+		trimmed := strings.TrimSpace(line)
+		token, hasToken := firstToken[number]
+		switch {
+		case trimmed == "":
+			lines[i] = ""
+		case hasToken && token.Position == len(line)-len(strings.TrimLeft(line, " \t")):
+			lines[i] = strings.Repeat(indent, lineDepth(token, depthBefore[number])) + trimmed
+		case !hasToken && strings.HasPrefix(trimmed, "//"):
+			lines[i] = strings.Repeat(indent, depth) + trimmed
+		default:
+			lines[i] = strings.TrimRight(line, " \t\r")
+		}
+
+		if hasToken {
+			depth = depthAfter[number]
+		}
+	}
+
+	return strings.Join(lines, "\n"), true
+}
+
+// lineDepth returns the indentation level of a line starting with the token,
+// closing brackets are aligned with the line that opened them.
+func lineDepth(first lexing.Token, depth int) int {
+	switch first.TokenType {
+	case lexing.RightBrace, lexing.RightBracket, lexing.RightParen:
+		if depth > 0 {
+			return depth - 1
+		}
+	}
+	return depth
+}
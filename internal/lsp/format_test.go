@@ -0,0 +1,72 @@
+package lsp
+
+import "testing"
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want string
+	}{
+		{
+			name: "nested blocks",
+			text: "fun f(a) {\nif (a) {\nprint a;\n}\n}\n",
+			want: "fun f(a) {\n  if (a) {\n    print a;\n  }\n}\n",
+		},
+		{
+			name: "trailing whitespace",
+			text: "var a = 1;   \nprint a;\t\n",
+			want: "var a = 1;\nprint a;\n",
+		},
+		{
+			name: "blank lines",
+			text: "{\n    var a = 1;\n   \n\n    print a;\n}",
+			want: "{\n  var a = 1;\n\n\n  print a;\n}",
+		},
+		{
+			name: "brackets across lines",
+			text: "var a = [\n1,\n2\n];\nprint len(\na\n);\n",
+			want: "var a = [\n  1,\n  2\n];\nprint len(\n  a\n);\n",
+		},
+		{
+			name: "comments",
+			text: "{\n// inside\nprint 1; // trailing   \n}\n",
+			want: "{\n  // inside\n  print 1; // trailing\n}\n",
+		},
+		{
+			name: "multi-line string",
+			text: "{\nprint \"a\n   b\";\n}\n",
+			want: "{\n  print \"a\n   b\";\n}\n",
+		},
+		{
+			name: "unbalanced closing brace",
+			text: "}\nprint 1;\n",
+			want: "}\nprint 1;\n",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := newDocument("file:///test.lox", test.text).format("  ")
+			if !ok {
+				t.Fatalf("format(%q) failed", test.text)
+			}
+			if got != test.want {
+				t.Errorf("format(%q) = %q, want %q", test.text, got, test.want)
+			}
+		})
+	}
+}
+
+func TestFormatLexerError(t *testing.T) {
+	if _, ok := newDocument("file:///test.lox", "print \"unterminated;\n").format("  "); ok {
+		t.Error("format of a document with a lexer error succeeded")
+	}
+}
+
+func TestFormatTabs(t *testing.T) {
+	got, _ := newDocument("file:///test.lox", "{\n  print 1;\n}").format("\t")
+	if want := "{\n\tprint 1;\n}"; got != want {
+		t.Errorf("format = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,263 @@
+package lsp
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"sort"
+	"strings"
+)
+
+var keywords = []string{
+	"and", "break", "case", "const", "continue", "else", "false", "for", "fun",
+	"if", "match", "nil", "or", "print", "return", "true", "var", "while",
+}
+
+func (s *Server) declarationAt(params TextDocumentPositionParams) (*document, *resolving.Declaration) {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return nil, nil
+	}
+
+	token, ok := doc.identifierAt(params.Position)
+	if !ok {
+		return doc, nil
+	}
+
+	declaration, ok := doc.resolver.Lookup(token.Offset)
+	if !ok {
+		return doc, nil
+	}
+	return doc, declaration
+}
+
+func (s *Server) definition(params TextDocumentPositionParams) interface{} {
+	doc, declaration := s.declarationAt(params)
+	if declaration == nil || declaration.Kind == resolving.Builtin {
+		return nil
+	}
+
+	return Location{URI: doc.uri, Range: doc.tokenRange(declaration.Name)}
+}
+
+func (s *Server) references(params ReferenceParams) interface{} {
+	doc, declaration := s.declarationAt(params.TextDocumentPositionParams)
+	locations := make([]Location, 0)
+	if declaration == nil {
+		return locations
+	}
+
+	if params.Context.IncludeDeclaration && declaration.Kind != resolving.Builtin {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(declaration.Name)})
+	}
+	for _, reference := range declaration.References {
+		locations = append(locations, Location{URI: doc.uri, Range: doc.tokenRange(reference)})
+	}
+	return locations
+}
+
+func (s *Server) hover(params TextDocumentPositionParams) interface{} {
+	doc, declaration := s.declarationAt(params)
+	if declaration == nil {
+		return nil
+	}
+
+	token, _ := doc.identifierAt(params.Position)
+	return Hover{
+		Contents: MarkupContent{
+			Kind:  "markdown",
+			Value: fmt.Sprintf("```lox\n%s\n```", describe(declaration)),
+		},
+		Range: doc.tokenRange(token),
+	}
+}
+
+func describe(declaration *resolving.Declaration) string {
+	name := declaration.Name.Lexeme
+
+	switch declaration.Kind {
+	case resolving.Function:
+		return fmt.Sprintf("fun %s(%s)", name, joinParams(declaration.Params))
+	case resolving.Parameter:
+		if declaration.Function != nil {
+			return fmt.Sprintf("param %s of %s", name, describe(declaration.Function))
+		}
+		return fmt.Sprintf("param %s", name)
+	case resolving.Builtin:
+		native := runtime.Builtins()[name]
+		switch native.(type) {
+		case *runtime.Module:
+			return fmt.Sprintf("native module %s", name)
+		case runtime.NamedCaller:
+			names := native.(runtime.NamedCaller).ParameterNames()
+			return fmt.Sprintf("native fun %s(%s)", name, strings.Join(names, ", "))
+		case runtime.Caller:
+			if count := native.(runtime.Caller).ParametersCount(); count >= 0 {
+				return fmt.Sprintf("native fun %s/%d", name, count)
+			}
+			return fmt.Sprintf("native fun %s/...", name)
+		}
+		return fmt.Sprintf("native var %s", name)
+	}
+
+	keyword := "var"
+	if declaration.Kind == resolving.Constant {
+		keyword = "const"
+	}
+	if declaration.Function == nil {
+		return fmt.Sprintf("%s %s // line %d", keyword, name, declaration.Name.Line)
+	}
+	return fmt.Sprintf("%s %s // local to %s", keyword, name, describe(declaration.Function))
+}
+
+func joinParams(params []lexing.Token) string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Lexeme
+	}
+	return strings.Join(names, ", ")
+}
+
+func (s *Server) documentSymbol(params DocumentSymbolParams) interface{} {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return []DocumentSymbol{}
+	}
+
+	symbols := doc.symbols(doc.statements)
+	if symbols == nil {
+		symbols = []DocumentSymbol{}
+	}
+	return symbols
+}
+
+func (d *document) symbols(statements []ast.Stmt) []DocumentSymbol {
+	var symbols []DocumentSymbol
+
+	for _, stmt := range statements {
+		switch stmt.(type) {
+		case ast.VarDeclarationStmt:
+			name := stmt.(ast.VarDeclarationStmt).Name
+			kind := SymbolVariable
+			if stmt.(ast.VarDeclarationStmt).Const {
+				kind = SymbolConstant
+			}
+			symbols = append(symbols, DocumentSymbol{
+				Name:           name.Lexeme,
+				Kind:           kind,
+				Range:          d.spanRange(stmt.Span()),
+				SelectionRange: d.tokenRange(name),
+			})
+		case ast.VarPatternStmt:
+			for _, name := range ast.PatternNames(stmt.(ast.VarPatternStmt).Pattern) {
+				symbols = append(symbols, DocumentSymbol{
+					Name:           name.Lexeme,
+					Kind:           SymbolVariable,
+					Range:          d.spanRange(stmt.Span()),
+					SelectionRange: d.tokenRange(name),
+				})
+			}
+		case ast.FunDeclarationStmt:
+			function := stmt.(ast.FunDeclarationStmt)
+			symbols = append(symbols, DocumentSymbol{
+				Name:           function.Name.Lexeme,
+				Detail:         fmt.Sprintf("fun(%s)", joinParams(function.Params)),
+				Kind:           SymbolFunction,
+				Range:          d.spanRange(function.Span()),
+				SelectionRange: d.tokenRange(function.Name),
+				Children:       d.symbols(function.Statement.Stmts),
+			})
+		case ast.BlockStmt:
+			symbols = append(symbols, d.symbols(stmt.(ast.BlockStmt).Stmts)...)
+		case ast.IfStmt:
+			symbols = append(symbols, d.symbols([]ast.Stmt{
+				stmt.(ast.IfStmt).IfStatement, stmt.(ast.IfStmt).ElseStatement,
+			})...)
+		case ast.ForStmt:
+			symbols = append(symbols, d.symbols([]ast.Stmt{
+				stmt.(ast.ForStmt).InitializerStmt, stmt.(ast.ForStmt).Statement,
+			})...)
+		}
+	}
+
+	return symbols
+}
+
+func (s *Server) completion(params TextDocumentPositionParams) interface{} {
+	items := make([]CompletionItem, 0)
+	for _, keyword := range keywords {
+		items = append(items, CompletionItem{Label: keyword, Kind: CompletionKeyword})
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range builtinNames() {
+		seen[name] = true
+		items = append(items, CompletionItem{
+			Label:  name,
+			Kind:   CompletionFunction,
+			Detail: describe(&resolving.Declaration{Name: lexing.Token{Lexeme: name}, Kind: resolving.Builtin}),
+		})
+	}
+
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return items
+	}
+
+	var declared []CompletionItem
+	for _, declaration := range doc.resolver.Declarations {
+		if seen[declaration.Name.Lexeme] {
+			continue
+		}
+		seen[declaration.Name.Lexeme] = true
+
+		kind := CompletionVariable
+		switch declaration.Kind {
+		case resolving.Function:
+			kind = CompletionFunction
+		case resolving.Constant:
+			kind = CompletionConstant
+		}
+		declared = append(declared, CompletionItem{
+			Label:  declaration.Name.Lexeme,
+			Kind:   kind,
+			Detail: describe(declaration),
+		})
+	}
+	sort.Slice(declared, func(i, j int) bool {
+		return declared[i].Label < declared[j].Label
+	})
+
+	return append(items, declared...)
+}
+
+func (s *Server) formatting(params DocumentFormattingParams) interface{} {
+	doc, ok := s.documents[params.TextDocument.URI]
+	if !ok {
+		return []TextEdit{}
+	}
+
+	indent := "\t"
+	if params.Options.InsertSpaces {
+		tabSize := params.Options.TabSize
+		if tabSize <= 0 {
+			tabSize = 4
+		}
+		indent = strings.Repeat(" ", tabSize)
+	}
+
+	formatted, ok := doc.format(indent)
+	if !ok || formatted == doc.text {
+		return []TextEdit{}
+	}
+
+	return []TextEdit{{
+		Range: Range{
+			Start: Position{},
+			End:   doc.position(len(doc.lines), len(doc.lines[len(doc.lines)-1])),
+		},
+		NewText: formatted,
+	}}
+}
@@ -0,0 +1,264 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"github.com/paw1a/golox/internal/framing"
+	"reflect"
+	"testing"
+)
+
+const testURI = "file:///test.lox"
+
+const testSource = `const LIMIT = 10;
+fun add(a, b) {
+  return a + b;
+}
+var total = add(LIMIT, 1);
+print total;
+print clock();
+`
+
+// openServer returns a server with the text open as testURI, together with
+// the buffer its notifications are written to.
+func openServer(text string) (*Server, *bytes.Buffer) {
+	var out bytes.Buffer
+	s := NewServer(bytes.NewReader(nil), &out)
+	s.didOpen(DidOpenTextDocumentParams{TextDocument: TextDocumentItem{URI: testURI, Text: text}})
+	return s, &out
+}
+
+func at(line int, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{
+		TextDocument: TextDocumentIdentifier{URI: testURI},
+		Position:     Position{Line: line, Character: character},
+	}
+}
+
+func span(line int, start int, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestDefinition(t *testing.T) {
+	s, _ := openServer(testSource)
+
+	tests := []struct {
+		name     string
+		position TextDocumentPositionParams
+		want     interface{}
+	}{
+		{"function", at(4, 13), Location{URI: testURI, Range: span(1, 4, 7)}},
+		{"constant", at(4, 17), Location{URI: testURI, Range: span(0, 6, 11)}},
+		{"parameter", at(2, 9), Location{URI: testURI, Range: span(1, 8, 9)}},
+		{"keyword", at(5, 2), nil},
+		{"builtin", at(6, 8), nil},
+		{"unknown document", TextDocumentPositionParams{
+			TextDocument: TextDocumentIdentifier{URI: "file:///other.lox"},
+		}, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := s.definition(test.position); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("definition = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	s, _ := openServer(testSource)
+
+	tests := []struct {
+		name               string
+		position           TextDocumentPositionParams
+		includeDeclaration bool
+		want               []Location
+	}{
+		{"with declaration", at(0, 8), true, []Location{
+			{URI: testURI, Range: span(0, 6, 11)},
+			{URI: testURI, Range: span(4, 16, 21)},
+		}},
+		{"without declaration", at(2, 13), false, []Location{
+			{URI: testURI, Range: span(2, 13, 14)},
+		}},
+		{"builtin", at(6, 8), true, []Location{
+			{URI: testURI, Range: span(6, 6, 11)},
+		}},
+		{"no identifier", at(3, 0), true, []Location{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			params := ReferenceParams{TextDocumentPositionParams: test.position}
+			params.Context.IncludeDeclaration = test.includeDeclaration
+			if got := s.references(params); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("references = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	s, _ := openServer(testSource)
+
+	tests := []struct {
+		name     string
+		position TextDocumentPositionParams
+		want     string
+		rng      Range
+	}{
+		{"function", at(4, 13), "fun add(a, b)", span(4, 12, 15)},
+		{"parameter", at(2, 9), "param a of fun add(a, b)", span(2, 9, 10)},
+		{"constant", at(0, 6), "const LIMIT // line 1", span(0, 6, 11)},
+		{"variable", at(5, 8), "var total // line 5", span(5, 6, 11)},
+		{"builtin", at(6, 6), "native fun clock/0", span(6, 6, 11)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			want := Hover{
+				Contents: MarkupContent{Kind: "markdown", Value: "```lox\n" + test.want + "\n```"},
+				Range:    test.rng,
+			}
+			if got := s.hover(test.position); !reflect.DeepEqual(got, want) {
+				t.Errorf("hover = %#v, want %#v", got, want)
+			}
+		})
+	}
+
+	if got := s.hover(at(3, 0)); got != nil {
+		t.Errorf("hover outside an identifier = %#v, want nil", got)
+	}
+}
+
+func TestDocumentSymbol(t *testing.T) {
+	s, _ := openServer(testSource)
+
+	got := s.documentSymbol(DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	symbols, ok := got.([]DocumentSymbol)
+	if !ok {
+		t.Fatalf("documentSymbol returned %T", got)
+	}
+
+	want := []struct {
+		name   string
+		kind   SymbolKind
+		detail string
+	}{
+		{"LIMIT", SymbolConstant, ""},
+		{"add", SymbolFunction, "fun(a, b)"},
+		{"total", SymbolVariable, ""},
+	}
+	if len(symbols) != len(want) {
+		t.Fatalf("documentSymbol = %#v, want %d symbols", symbols, len(want))
+	}
+	for i, symbol := range symbols {
+		if symbol.Name != want[i].name || symbol.Kind != want[i].kind || symbol.Detail != want[i].detail {
+			t.Errorf("symbol %d = %s %d %q, want %s %d %q", i,
+				symbol.Name, symbol.Kind, symbol.Detail, want[i].name, want[i].kind, want[i].detail)
+		}
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	s, _ := openServer(testSource)
+
+	items := s.completion(at(6, 0)).([]CompletionItem)
+	kinds := make(map[string]CompletionItemKind)
+	for _, item := range items {
+		kinds[item.Label] = item.Kind
+	}
+
+	tests := []struct {
+		label string
+		kind  CompletionItemKind
+	}{
+		{"while", CompletionKeyword},
+		{"clock", CompletionFunction},
+		{"add", CompletionFunction},
+		{"LIMIT", CompletionConstant},
+		{"total", CompletionVariable},
+		{"a", CompletionVariable},
+	}
+	for _, test := range tests {
+		if kind, ok := kinds[test.label]; !ok || kind != test.kind {
+			t.Errorf("completion %q = %d (found %v), want %d", test.label, kind, ok, test.kind)
+		}
+	}
+}
+
+func TestFormatting(t *testing.T) {
+	tests := []struct {
+		name    string
+		text    string
+		options FormattingOptions
+		want    []TextEdit
+	}{
+		{"spaces", "{\nprint 1;\n}", FormattingOptions{TabSize: 2, InsertSpaces: true}, []TextEdit{{
+			Range:   Range{End: Position{Line: 2, Character: 1}},
+			NewText: "{\n  print 1;\n}",
+		}}},
+		{"tabs", "{\nprint 1;\n}\n", FormattingOptions{}, []TextEdit{{
+			Range:   Range{End: Position{Line: 3}},
+			NewText: "{\n\tprint 1;\n}\n",
+		}}},
+		{"formatted", "{\n    print 1;\n}\n", FormattingOptions{InsertSpaces: true}, []TextEdit{}},
+		{"lexer error", "print \"a;\n", FormattingOptions{}, []TextEdit{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, _ := openServer(test.text)
+			got := s.formatting(DocumentFormattingParams{
+				TextDocument: TextDocumentIdentifier{URI: testURI},
+				Options:      test.options,
+			})
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("formatting = %#v, want %#v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Diagnostic
+	}{
+		{"valid", "print 1;\n", []Diagnostic{}},
+		{"parse error", "print ;\n", []Diagnostic{{
+			Range:    span(0, 6, 7),
+			Severity: SeverityError,
+			Source:   "golox",
+			Message:  "expect expression",
+		}}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, out := openServer(test.text)
+
+			body, err := framing.ReadMessage(bufio.NewReader(out))
+			if err != nil {
+				t.Fatalf("ReadMessage: %v", err)
+			}
+			var message struct {
+				Method string                   `json:"method"`
+				Params PublishDiagnosticsParams `json:"params"`
+			}
+			if err := json.Unmarshal(body, &message); err != nil {
+				t.Fatalf("invalid notification %s: %v", body, err)
+			}
+
+			if message.Method != "textDocument/publishDiagnostics" || message.Params.URI != testURI {
+				t.Fatalf("notification = %s", body)
+			}
+			if !reflect.DeepEqual(message.Params.Diagnostics, test.want) {
+				t.Errorf("diagnostics = %#v, want %#v", message.Params.Diagnostics, test.want)
+			}
+		})
+	}
+}
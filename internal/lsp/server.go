@@ -0,0 +1,223 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/paw1a/golox/internal/framing"
+	"io"
+	"sync"
+)
+
+// Server is a language server speaking LSP over a pair of streams.
+type Server struct {
+	in  *bufio.Reader
+	out io.Writer
+	mu  sync.Mutex
+
+	documents map[string]*document
+	shutdown  bool
+}
+
+// ErrExitWithoutShutdown is returned when the client sends "exit" without
+// asking the server to shut down first.
+var ErrExitWithoutShutdown = errors.New("exit received before shutdown")
+
+// Serve runs a language server until the client sends "exit" or the
+// input stream is closed. It returns ErrExitWithoutShutdown if "exit" was
+// not preceded by "shutdown", so that the process ends with a failure.
+func Serve(in io.Reader, out io.Writer) error {
+	return NewServer(in, out).Run()
+}
+
+func (s *Server) Run() error {
+	for {
+		body, err := framing.ReadMessage(s.in)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			s.replyError(nil, parseErrorCode, err.Error())
+			continue
+		}
+
+		if req.Method == "exit" {
+			if !s.shutdown {
+				return ErrExitWithoutShutdown
+			}
+			return nil
+		}
+		s.handle(req)
+	}
+}
+
+func (s *Server) handle(req request) {
+	defer func() {
+		if err := recover(); err != nil {
+			if req.ID != nil {
+				s.replyError(req.ID, internalErrorCode, fmt.Sprintf("%v", err))
+			}
+		}
+	}()
+
+	var result interface{}
+	var err error
+
+	switch req.Method {
+	case "initialize":
+		result = s.initialize()
+	case "initialized":
+		return
+	case "shutdown":
+		s.shutdown = true
+	case "textDocument/didOpen":
+		var params DidOpenTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			s.didOpen(params)
+		}
+	case "textDocument/didChange":
+		var params DidChangeTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			s.didChange(params)
+		}
+	case "textDocument/didClose":
+		var params DidCloseTextDocumentParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			s.didClose(params)
+		}
+	case "textDocument/definition":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.definition(params)
+		}
+	case "textDocument/references":
+		var params ReferenceParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.references(params)
+		}
+	case "textDocument/hover":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.hover(params)
+		}
+	case "textDocument/documentSymbol":
+		var params DocumentSymbolParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.documentSymbol(params)
+		}
+	case "textDocument/completion":
+		var params TextDocumentPositionParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.completion(params)
+		}
+	case "textDocument/formatting":
+		var params DocumentFormattingParams
+		if err = json.Unmarshal(req.Params, &params); err == nil {
+			result = s.formatting(params)
+		}
+	default:
+		if req.ID != nil {
+			s.replyError(req.ID, methodNotFoundCode, fmt.Sprintf("method %s not supported", req.Method))
+		}
+		return
+	}
+
+	if req.ID == nil {
+		return
+	}
+	if err != nil {
+		s.replyError(req.ID, invalidParamsCode, err.Error())
+		return
+	}
+	s.write(response{JSONRPC: "2.0", ID: req.ID, Result: result})
+}
+
+func (s *Server) initialize() interface{} {
+	return map[string]interface{}{
+		"capabilities": map[string]interface{}{
+			"textDocumentSync":           1,
+			"definitionProvider":         true,
+			"referencesProvider":         true,
+			"hoverProvider":              true,
+			"documentSymbolProvider":     true,
+			"documentFormattingProvider": true,
+			"completionProvider":         map[string]interface{}{},
+		},
+		"serverInfo": map[string]interface{}{
+			"name": "golox",
+		},
+	}
+}
+
+func (s *Server) didOpen(params DidOpenTextDocumentParams) {
+	doc := newDocument(params.TextDocument.URI, params.TextDocument.Text)
+	s.documents[doc.uri] = doc
+	s.publishDiagnostics(doc)
+}
+
+func (s *Server) didChange(params DidChangeTextDocumentParams) {
+	if len(params.ContentChanges) == 0 {
+		return
+	}
+
+	text := params.ContentChanges[len(params.ContentChanges)-1].Text
+	doc := newDocument(params.TextDocument.URI, text)
+	s.documents[doc.uri] = doc
+	s.publishDiagnostics(doc)
+}
+
+func (s *Server) didClose(params DidCloseTextDocumentParams) {
+	delete(s.documents, params.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         params.TextDocument.URI,
+		Diagnostics: []Diagnostic{},
+	})
+}
+
+func (s *Server) publishDiagnostics(doc *document) {
+	diagnostics := doc.diagnostics
+	if diagnostics == nil {
+		diagnostics = []Diagnostic{}
+	}
+	s.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{
+		URI:         doc.uri,
+		Diagnostics: diagnostics,
+	})
+}
+
+func (s *Server) notify(method string, params interface{}) {
+	s.write(notification{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+func (s *Server) replyError(id *json.RawMessage, code int, message string) {
+	s.write(errorResponse{
+		JSONRPC: "2.0",
+		ID:      id,
+		Error:   responseError{Code: code, Message: message},
+	})
+}
+
+func (s *Server) write(message interface{}) {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	framing.WriteMessage(s.out, body)
+}
+
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		in:        bufio.NewReader(in),
+		out:       out,
+		documents: make(map[string]*document),
+	}
+}
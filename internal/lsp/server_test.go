@@ -0,0 +1,119 @@
+package lsp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/paw1a/golox/internal/framing"
+	"io"
+	"testing"
+)
+
+// message is any message written by the server.
+type message struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+// run feeds the messages to a server and returns everything it wrote back.
+func run(t *testing.T, messages ...string) ([]message, error) {
+	var in bytes.Buffer
+	for _, m := range messages {
+		if err := framing.WriteMessage(&in, []byte(m)); err != nil {
+			t.Fatalf("WriteMessage: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	runErr := NewServer(&in, &out).Run()
+
+	var replies []message
+	reader := bufio.NewReader(&out)
+	for {
+		body, err := framing.ReadMessage(reader)
+		if errors.Is(err, io.EOF) {
+			return replies, runErr
+		}
+		if err != nil {
+			t.Fatalf("ReadMessage: %v", err)
+		}
+
+		var reply message
+		if err := json.Unmarshal(body, &reply); err != nil {
+			t.Fatalf("invalid message %s: %v", body, err)
+		}
+		replies = append(replies, reply)
+	}
+}
+
+func TestSession(t *testing.T) {
+	replies, err := run(t,
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"initialized","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.lox","text":"var a = 1;\nprint a;\n"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.lox"},"position":{"line":1,"character":6}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"textDocument/unknown","params":{}}`,
+		`{"jsonrpc":"2.0","id":4,"method":"textDocument/definition","params":[]}`,
+		`not json`,
+		`{"jsonrpc":"2.0","id":5,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if len(replies) != 7 {
+		t.Fatalf("got %d messages, want 7: %+v", len(replies), replies)
+	}
+
+	var initialize struct {
+		Capabilities map[string]interface{} `json:"capabilities"`
+	}
+	if err := json.Unmarshal(replies[0].Result, &initialize); err != nil || initialize.Capabilities["hoverProvider"] != true {
+		t.Errorf("initialize result = %s", replies[0].Result)
+	}
+
+	if replies[1].Method != "textDocument/publishDiagnostics" {
+		t.Errorf("message 1 = %+v, want diagnostics", replies[1])
+	}
+
+	var hover Hover
+	if err := json.Unmarshal(replies[2].Result, &hover); err != nil || hover.Contents.Value != "```lox\nvar a // line 1\n```" {
+		t.Errorf("hover result = %s", replies[2].Result)
+	}
+
+	errorCodes := []struct {
+		index int
+		code  int
+	}{
+		{3, methodNotFoundCode},
+		{4, invalidParamsCode},
+		{5, parseErrorCode},
+	}
+	for _, test := range errorCodes {
+		if reply := replies[test.index]; reply.Error == nil || reply.Error.Code != test.code {
+			t.Errorf("message %d = %+v, want error %d", test.index, reply, test.code)
+		}
+	}
+
+	if reply := replies[6]; reply.ID == nil || *reply.ID != 5 || reply.Error != nil {
+		t.Errorf("shutdown reply = %+v", reply)
+	}
+}
+
+func TestExitWithoutShutdown(t *testing.T) {
+	_, err := run(t, `{"jsonrpc":"2.0","method":"exit"}`)
+	if err != ErrExitWithoutShutdown {
+		t.Errorf("Run = %v, want %v", err, ErrExitWithoutShutdown)
+	}
+}
+
+func TestTruncatedInput(t *testing.T) {
+	var out bytes.Buffer
+	err := NewServer(bytes.NewReader([]byte("Content-Length: 10\r\n\r\n{}")), &out).Run()
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("Run = %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+}
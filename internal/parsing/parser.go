@@ -10,11 +10,14 @@ import (
 )
 
 type Parser struct {
+	File string
+
 	tokens  []lexing.Token
 	current int
 
-	Errors []error
-	lines  []string
+	Errors   []error
+	Warnings []error
+	lines    []string
 
 	isLoopScope bool
 	isFuncScope bool
@@ -30,6 +33,19 @@ func (p *Parser) Parse() []ast.Stmt {
 	return statements
 }
 
+// ParseExpression parses the tokens as a single expression, it is used to
+// evaluate expressions typed by the user outside of a program.
+func (p *Parser) ParseExpression() ast.Expr {
+	defer p.parseRecoverFunc()
+
+	expr := p.expression()
+	if !p.isEof() {
+		p.parseError(p.peek(), "unexpected token after expression")
+	}
+
+	return expr
+}
+
 func (p *Parser) declaration() ast.Stmt {
 	defer p.parseRecoverFunc()
 
@@ -38,6 +54,10 @@ func (p *Parser) declaration() ast.Stmt {
 		return p.varDeclaration()
 	}
 
+	if p.match(lexing.Const) {
+		return p.constDeclaration(p.advance())
+	}
+
 	if p.match(lexing.Fun) {
 		p.advance()
 		return p.funDeclaration()
@@ -47,6 +67,11 @@ func (p *Parser) declaration() ast.Stmt {
 }
 
 func (p *Parser) varDeclaration() ast.Stmt {
+	start := p.previous().Start()
+	if p.match(lexing.LeftBracket, lexing.LeftBrace) {
+		return p.varPatternDeclaration(start)
+	}
+
 	varName := p.requireToken(lexing.Identifier, "variable name expected")
 
 	var initializer ast.Expr
@@ -59,27 +84,160 @@ func (p *Parser) varDeclaration() ast.Stmt {
 	return ast.VarDeclarationStmt{
 		Name:        varName,
 		Initializer: initializer,
+		Range:       p.spanFrom(start),
 	}
 }
 
-func (p *Parser) funDeclaration() ast.Stmt {
-	funcName := p.requireToken(lexing.Identifier, "function name expected")
-	p.requireToken(lexing.LeftParen, "function declaration expect '('")
+func (p *Parser) constDeclaration(constToken lexing.Token) ast.Stmt {
+	name := p.requireToken(lexing.Identifier, "constant name expected")
+	p.requireToken(lexing.Equal, "constant declaration expect '='")
+	initializer := p.expression()
+	p.requireToken(lexing.Semicolon, "';' expected")
 
-	parameters := make([]lexing.Token, 0)
-	if !p.match(lexing.RightParen) {
-		parameters = append(parameters, p.requireToken(lexing.Identifier,
-			"function declaration expect identifier as param name"))
-		for p.match(lexing.Comma) {
+	return ast.VarDeclarationStmt{
+		Name:        name,
+		Initializer: initializer,
+		Const:       true,
+		Range:       p.spanFrom(constToken.Start()),
+	}
+}
+
+func (p *Parser) varPatternDeclaration(start lexing.Location) ast.Stmt {
+	pattern := p.pattern(true)
+	p.requireToken(lexing.Equal, "destructuring declaration expect '='")
+	initializer := p.expression()
+	p.requireToken(lexing.Semicolon, "';' expected")
+
+	return ast.VarPatternStmt{
+		Pattern:     pattern,
+		Initializer: initializer,
+		Range:       p.spanFrom(start),
+	}
+}
+
+// pattern parses a destructuring pattern. Declaration patterns bind names
+// and may destructure maps, assignment patterns assign to variables and
+// index expressions.
+func (p *Parser) pattern(declaration bool) ast.Expr {
+	switch {
+	case p.match(lexing.LeftBracket):
+		return p.arrayPattern(p.advance(), func() ast.Expr {
+			return p.pattern(declaration)
+		})
+	case p.match(lexing.LeftBrace) && declaration:
+		return p.mapPattern(p.advance())
+	case declaration:
+		name := p.requireToken(lexing.Identifier, "pattern expect variable name")
+		return ast.VariableExpr{Name: name, Range: p.tokenSpan(name)}
+	}
+
+	target := p.call()
+	if !isAssignable(target) {
+		p.parseError(p.previous(), "invalid assignment target")
+	}
+	return target
+}
+
+// arrayPattern parses the elements of an array pattern, each of which is
+// parsed by the element function.
+func (p *Parser) arrayPattern(bracket lexing.Token, element func() ast.Expr) ast.Expr {
+	elements := make([]ast.Expr, 0)
+	var rest ast.Expr
+
+	for !p.match(lexing.RightBracket) && !p.isEof() {
+		if p.match(lexing.Ellipsis) {
 			p.advance()
-			if len(parameters) >= 255 {
-				p.parseError(p.peek(), "declared more than 255 parameters")
-				break
+			rest = element()
+			break
+		}
+
+		elements = append(elements, element())
+		if !p.match(lexing.RightBracket) {
+			p.requireToken(lexing.Comma, "array pattern expect ',' between elements")
+		}
+	}
+
+	if rest != nil {
+		p.requireToken(lexing.RightBracket, "rest element must be last in array pattern")
+	} else {
+		p.requireToken(lexing.RightBracket, "array pattern expect ']'")
+	}
+
+	return ast.ArrayPattern{
+		Bracket:  bracket,
+		Elements: elements,
+		Rest:     rest,
+		Range:    p.spanFrom(bracket.Start()),
+	}
+}
+
+// mapPattern parses entries of the form key or key: pattern, a key alone
+// binds the variable of the same name.
+func (p *Parser) mapPattern(brace lexing.Token) ast.Expr {
+	keys := make([]lexing.Token, 0)
+	values := make([]ast.Expr, 0)
+
+	for !p.match(lexing.RightBrace) && !p.isEof() {
+		var key lexing.Token
+		if p.match(lexing.String) {
+			key = p.advance()
+		} else {
+			key = p.requireToken(lexing.Identifier, "map pattern expect key name")
+		}
+
+		if p.match(lexing.Colon) {
+			p.advance()
+			values = append(values, p.pattern(true))
+		} else if key.TokenType == lexing.Identifier {
+			values = append(values, ast.VariableExpr{Name: key, Range: p.tokenSpan(key)})
+		} else {
+			p.parseError(p.peek(), "map pattern expect ':' after string key")
+		}
+		keys = append(keys, key)
+
+		if !p.match(lexing.RightBrace) {
+			p.requireToken(lexing.Comma, "map pattern expect ',' between entries")
+		}
+	}
+
+	p.requireToken(lexing.RightBrace, "map pattern expect '}'")
+	return ast.MapPattern{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+		Range:  p.spanFrom(brace.Start()),
+	}
+}
+
+// assignmentPattern parses an array pattern if it is followed by '=', and
+// otherwise leaves the parser where it was so that the tokens are parsed
+// again as an expression.
+func (p *Parser) assignmentPattern() (pattern ast.Expr, ok bool) {
+	current, warnings := p.current, len(p.Warnings)
+	defer func() {
+		if r := recover(); r != nil {
+			if _, isParseError := r.(*Error); !isParseError {
+				panic(r)
 			}
-			parameters = append(parameters, p.requireToken(lexing.Identifier,
-				"function declaration expect identifier as param name"))
+			p.current, p.Warnings = current, p.Warnings[:warnings]
+			pattern, ok = nil, false
 		}
+	}()
+
+	pattern = p.pattern(false)
+	if !p.match(lexing.Equal) {
+		p.current, p.Warnings = current, p.Warnings[:warnings]
+		return nil, false
 	}
+	return pattern, true
+}
+
+func (p *Parser) funDeclaration() ast.Stmt {
+	start := p.previous().Start()
+	funcName := p.requireToken(lexing.Identifier, "function name expected")
+	p.requireToken(lexing.LeftParen, "function declaration expect '('")
+
+	parameters, defaults, rest := p.parameters("function declaration")
 	p.requireToken(lexing.RightParen, "function declaration expect ')'")
 
 	p.requireToken(lexing.LeftBrace, "expect '{' before function body")
@@ -97,8 +255,60 @@ func (p *Parser) funDeclaration() ast.Stmt {
 	return ast.FunDeclarationStmt{
 		Name:      funcName,
 		Params:    parameters,
+		Defaults:  defaults,
+		Rest:      rest,
 		Statement: statement.(ast.BlockStmt),
+		Range:     p.spanFrom(start),
+	}
+}
+
+// parameters parses a parameter list in which parameters may have default
+// values and the last one may be a rest parameter collecting the remaining
+// arguments. Defaults holds nil for parameters without a default value.
+func (p *Parser) parameters(kind string) ([]lexing.Token, []ast.Expr, bool) {
+	parameters := make([]lexing.Token, 0)
+	defaults := make([]ast.Expr, 0)
+	rest := false
+
+	if p.match(lexing.RightParen) {
+		return parameters, defaults, rest
+	}
+
+	for {
+		if len(parameters) >= 255 {
+			p.parseError(p.peek(), "declared more than 255 parameters")
+		}
+		if rest {
+			p.parseError(p.peek(), "rest parameter must be last")
+		}
+
+		if p.match(lexing.Ellipsis) {
+			p.advance()
+			rest = true
+		}
+		parameter := p.requireToken(lexing.Identifier, kind+" expect identifier as param name")
+
+		var defaultValue ast.Expr
+		if p.match(lexing.Equal) {
+			equal := p.advance()
+			if rest {
+				p.parseError(equal, "rest parameter can't have default value")
+			}
+			defaultValue = p.assignment()
+		} else if !rest && len(defaults) > 0 && defaults[len(defaults)-1] != nil {
+			p.parseError(parameter, "parameter without default value follows parameter with default value")
+		}
+
+		parameters = append(parameters, parameter)
+		defaults = append(defaults, defaultValue)
+
+		if !p.match(lexing.Comma) {
+			break
+		}
+		p.advance()
 	}
+
+	return parameters, defaults, rest
 }
 
 func (p *Parser) statement() ast.Stmt {
@@ -106,6 +316,10 @@ func (p *Parser) statement() ast.Stmt {
 	case p.match(lexing.LeftBrace):
 		p.advance()
 		return p.blockStatement()
+	case p.match(lexing.Print):
+		return p.printStatement(p.advance())
+	case p.match(lexing.Match):
+		return p.matchStatement(p.advance())
 	case p.match(lexing.If):
 		p.advance()
 		return p.ifStatement()
@@ -150,20 +364,24 @@ func (p *Parser) returnStatement(returnToken lexing.Token) ast.Stmt {
 	return ast.ReturnStmt{
 		ReturnToken: returnToken,
 		Expr:        returnExpr,
+		Range:       p.spanFrom(returnToken.Start()),
 	}
 }
 
 func (p *Parser) breakStatement() ast.Stmt {
+	start := p.previous().Start()
 	p.requireToken(lexing.Semicolon, "expect ';' after break statement")
-	return ast.BreakStmt{}
+	return ast.BreakStmt{Range: p.spanFrom(start)}
 }
 
 func (p *Parser) continueStatement() ast.Stmt {
+	start := p.previous().Start()
 	p.requireToken(lexing.Semicolon, "expect ';' after continue statement")
-	return ast.ContinueStmt{}
+	return ast.ContinueStmt{Range: p.spanFrom(start)}
 }
 
 func (p *Parser) forStatement() ast.Stmt {
+	start := p.previous().Start()
 	p.requireToken(lexing.LeftParen, "for statement expect '('")
 
 	var initializerStmt ast.Stmt
@@ -181,7 +399,10 @@ func (p *Parser) forStatement() ast.Stmt {
 	if !p.match(lexing.Semicolon) {
 		conditionExpr = p.expression()
 	} else {
-		conditionExpr = ast.LiteralExpr{LiteralValue: true}
+		conditionExpr = ast.LiteralExpr{
+			LiteralValue: true,
+			Range:        p.tokenSpan(p.peek()),
+		}
 	}
 	p.requireToken(lexing.Semicolon,
 		"for statement expect ';' between condition and increment expressions")
@@ -207,10 +428,12 @@ func (p *Parser) forStatement() ast.Stmt {
 		ConditionExpr:   conditionExpr,
 		IncrementExpr:   incrementExpr,
 		Statement:       statement,
+		Range:           p.spanFrom(start),
 	}
 }
 
 func (p *Parser) whileStatement() ast.Stmt {
+	start := p.previous().Start()
 	p.requireToken(lexing.LeftParen, "while statement expect '(' before condition")
 	conditionExpr := p.expression()
 	p.requireToken(lexing.RightParen, "while statement expect ')' after condition")
@@ -228,10 +451,12 @@ func (p *Parser) whileStatement() ast.Stmt {
 	return ast.ForStmt{
 		ConditionExpr: conditionExpr,
 		Statement:     statement,
+		Range:         p.spanFrom(start),
 	}
 }
 
 func (p *Parser) ifStatement() ast.Stmt {
+	start := p.previous().Start()
 	p.requireToken(lexing.LeftParen, "if statement expect '(' before condition")
 	conditionExpr := p.expression()
 	p.requireToken(lexing.RightParen, "if statement expect ')' after condition")
@@ -247,10 +472,12 @@ func (p *Parser) ifStatement() ast.Stmt {
 		ConditionExpr: conditionExpr,
 		IfStatement:   ifStatement,
 		ElseStatement: elseStatement,
+		Range:         p.spanFrom(start),
 	}
 }
 
 func (p *Parser) blockStatement() ast.Stmt {
+	start := p.previous().Start()
 	var stmts []ast.Stmt
 
 	for !p.match(lexing.RightBrace) && !p.isEof() {
@@ -259,36 +486,213 @@ func (p *Parser) blockStatement() ast.Stmt {
 
 	p.requireToken(lexing.RightBrace, "'}' end of block expected")
 
-	return ast.BlockStmt{Stmts: stmts}
+	return ast.BlockStmt{
+		Stmts: stmts,
+		Range: p.spanFrom(start),
+	}
 }
 
 func (p *Parser) expressionStatement() ast.Stmt {
 	expr := p.expression()
 	p.requireToken(lexing.Semicolon, "';' expected")
-	return ast.ExpressionStmt{Expr: expr}
+	return ast.ExpressionStmt{
+		Expr:  expr,
+		Range: p.spanFrom(expr.Span().Start),
+	}
 }
 
-func (p *Parser) expression() ast.Expr {
-	return p.comma()
+func (p *Parser) printStatement(printToken lexing.Token) ast.Stmt {
+	expr := p.expression()
+	p.requireToken(lexing.Semicolon, "';' expected")
+	return ast.PrintStmt{
+		Expr:  expr,
+		Range: p.spanFrom(printToken.Start()),
+	}
 }
 
-func (p *Parser) lambda() ast.Expr {
-	p.requireToken(lexing.LeftParen, "lambda declaration expect '('")
+// matchStatement parses a match whose arms are statements.
+func (p *Parser) matchStatement(keyword lexing.Token) ast.Stmt {
+	subject, arms := p.matchArms(func() ast.Node {
+		return p.statement()
+	})
+	return ast.MatchStmt{
+		Keyword: keyword,
+		Subject: subject,
+		Arms:    arms,
+		Range:   p.spanFrom(keyword.Start()),
+	}
+}
 
-	parameters := make([]lexing.Token, 0)
-	if !p.match(lexing.RightParen) {
-		parameters = append(parameters, p.requireToken(lexing.Identifier,
-			"lambda declaration expect identifier as param name"))
-		for p.match(lexing.Comma) {
+// matchExpr parses a match whose arms are expressions or blocks, the ';'
+// after the last expression may be left out.
+func (p *Parser) matchExpr(keyword lexing.Token) ast.Expr {
+	subject, arms := p.matchArms(func() ast.Node {
+		if p.match(lexing.LeftBrace) {
 			p.advance()
-			if len(parameters) >= 255 {
-				p.parseError(p.peek(), "declared more than 255 parameters")
+			return p.blockStatement()
+		}
+
+		expr := p.expression()
+		if !p.match(lexing.RightBrace) {
+			p.requireToken(lexing.Semicolon, "match case expect ';' after expression")
+		}
+		return expr
+	})
+	return ast.MatchExpr{
+		Keyword: keyword,
+		Subject: subject,
+		Arms:    arms,
+		Range:   p.spanFrom(keyword.Start()),
+	}
+}
+
+func (p *Parser) matchArms(body func() ast.Node) (ast.Expr, []ast.MatchArm) {
+	p.requireToken(lexing.LeftParen, "match expect '(' before subject")
+	subject := p.expression()
+	p.requireToken(lexing.RightParen, "match expect ')' after subject")
+	p.requireToken(lexing.LeftBrace, "match expect '{' before cases")
+
+	arms := make([]ast.MatchArm, 0)
+	var matched []ast.Expr
+	for !p.match(lexing.RightBrace) && !p.isEof() {
+		arm := p.matchArm(matched, body)
+		arms = append(arms, arm)
+		switch {
+		case arm.Guard != nil:
+		case arm.Keyword.TokenType == lexing.Else:
+			matched = append(matched, nil)
+		default:
+			matched = append(matched, arm.Patterns...)
+		}
+	}
+
+	p.requireToken(lexing.RightBrace, "match expect '}' after cases")
+	return subject, arms
+}
+
+// matchArm parses a case or the else arm of a match. Matched holds the
+// patterns of the unguarded arms before it, with nil for an else arm, and
+// is used to warn about patterns that can never match.
+func (p *Parser) matchArm(matched []ast.Expr, body func() ast.Node) ast.MatchArm {
+	keyword := p.peek()
+	var patterns []ast.Expr
+
+	switch {
+	case p.match(lexing.Else):
+		p.advance()
+		if covered(matched, nil) {
+			p.warning(keyword, "unreachable case, every value is matched by an earlier case")
+		}
+	case p.match(lexing.Case):
+		p.advance()
+		for {
+			start := p.peek()
+			pattern := p.matchPattern()
+			if covered(matched, pattern) {
+				p.warning(start, "unreachable case, the pattern is matched by an earlier case")
+			}
+			patterns = append(patterns, pattern)
+
+			if !p.match(lexing.Comma) {
 				break
 			}
-			parameters = append(parameters, p.requireToken(lexing.Identifier,
-				"lambda declaration expect identifier as param name"))
+			p.advance()
+		}
+		for _, pattern := range patterns {
+			if names := ast.PatternNames(pattern); len(patterns) > 1 && len(names) > 0 {
+				p.parseError(names[0], "alternative patterns can't bind names")
+			}
+		}
+	default:
+		p.parseError(p.peek(), "match expect 'case' or 'else'")
+	}
+
+	var guard ast.Expr
+	if p.match(lexing.If) {
+		p.advance()
+		guard = p.assignment()
+	}
+	p.requireToken(lexing.Arrow, "match case expect '=>'")
+
+	return ast.MatchArm{
+		Keyword:  keyword,
+		Patterns: patterns,
+		Guard:    guard,
+		Body:     body(),
+		Range:    p.spanFrom(keyword.Start()),
+	}
+}
+
+// matchPattern parses a literal, a name binding the matched value or an
+// array pattern matching arrays by shape.
+func (p *Parser) matchPattern() ast.Expr {
+	switch {
+	case p.match(lexing.LeftBracket):
+		return p.arrayPattern(p.advance(), p.matchPattern)
+	case p.match(lexing.Identifier):
+		name := p.advance()
+		return ast.VariableExpr{Name: name, Range: p.tokenSpan(name)}
+	case p.match(lexing.Minus):
+		minus := p.advance()
+		number := p.requireToken(lexing.Number, "match pattern expect number after '-'")
+		return ast.LiteralExpr{LiteralValue: -number.Literal.(float64), Range: p.spanFrom(minus.Start())}
+	case p.match(lexing.Number, lexing.String, lexing.True, lexing.False, lexing.Nil):
+		return p.primary()
+	}
+
+	p.parseError(p.peek(), "match expect pattern")
+	return nil
+}
+
+// covered reports whether every value the pattern matches is matched by
+// one of the earlier patterns. A nil pattern stands for an else arm, which
+// matches everything.
+func covered(matched []ast.Expr, pattern ast.Expr) bool {
+	for _, earlier := range matched {
+		if covers(earlier, pattern) {
+			return true
 		}
 	}
+	return false
+}
+
+func covers(earlier ast.Expr, pattern ast.Expr) bool {
+	switch earlier.(type) {
+	case nil, ast.VariableExpr:
+		return true
+	case ast.LiteralExpr:
+		literal, ok := pattern.(ast.LiteralExpr)
+		return ok && literal.LiteralValue == earlier.(ast.LiteralExpr).LiteralValue
+	case ast.ArrayPattern:
+		array, ok := pattern.(ast.ArrayPattern)
+		shape := earlier.(ast.ArrayPattern)
+		switch {
+		case !ok:
+			return false
+		case shape.Rest == nil && (array.Rest != nil || len(array.Elements) != len(shape.Elements)):
+			return false
+		case len(array.Elements) < len(shape.Elements):
+			return false
+		}
+		for index, element := range shape.Elements {
+			if !covers(element, array.Elements[index]) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func (p *Parser) expression() ast.Expr {
+	return p.comma()
+}
+
+func (p *Parser) lambda() ast.Expr {
+	start := p.previous().Start()
+	p.requireToken(lexing.LeftParen, "lambda declaration expect '('")
+
+	parameters, defaults, rest := p.parameters("lambda declaration")
 	p.requireToken(lexing.RightParen, "lambda declaration expect ')'")
 
 	p.requireToken(lexing.LeftBrace, "expect '{' before lambda body")
@@ -305,7 +709,10 @@ func (p *Parser) lambda() ast.Expr {
 
 	return ast.LambdaExpr{
 		Params:    parameters,
+		Defaults:  defaults,
+		Rest:      rest,
 		Statement: statement.(ast.BlockStmt),
+		Range:     p.spanFrom(start),
 	}
 }
 
@@ -320,6 +727,7 @@ func (p *Parser) comma() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -332,37 +740,79 @@ func (p *Parser) assignment() ast.Expr {
 		return p.lambda()
 	}
 
-	expr := p.logicalOr()
+	if p.match(lexing.LeftBracket) {
+		if pattern, ok := p.assignmentPattern(); ok {
+			p.advance()
+			value := p.assignment()
+			return ast.AssignExpr{
+				Variable:    pattern,
+				Initializer: value,
+				Range:       p.spanFrom(pattern.Span().Start),
+			}
+		}
+	}
+
+	expr := p.coalesce()
 
 	if p.match(lexing.Equal) {
 		equalToken := p.advance()
 		value := p.assignment()
 
-		switch expr.(type) {
-		case ast.VariableExpr:
-			return ast.AssignExpr{
-				Variable:    expr.(ast.VariableExpr),
-				Initializer: value,
-			}
-		case ast.IndexExpr:
+		if isAssignable(expr) {
 			return ast.AssignExpr{
-				Variable:    expr.(ast.IndexExpr),
+				Variable:    expr,
 				Initializer: value,
+				Range:       p.spanFrom(expr.Span().Start),
 			}
 		}
 
 		p.parseError(equalToken, "invalid assignment target")
 	}
 
+	if p.match(lexing.PlusEqual, lexing.MinusEqual, lexing.StarEqual, lexing.SlashEqual, lexing.PercentEqual) {
+		operator := p.advance()
+		value := p.assignment()
+		if !isAssignable(expr) {
+			p.parseError(operator, "invalid assignment target")
+		}
+		return ast.CompoundAssignExpr{
+			Variable: expr,
+			Operator: operator,
+			Value:    value,
+			Range:    p.spanFrom(expr.Span().Start),
+		}
+	}
+
 	if p.match(lexing.Question) {
 		p.advance()
-		trueValue := p.logicalOr()
+		trueValue := p.coalesce()
 		p.requireToken(lexing.Colon, "ternary operator expect ':'")
-		falseValue := p.logicalOr()
+		falseValue := p.coalesce()
 		return ast.TernaryExpr{
 			Condition: expr,
 			TrueExpr:  trueValue,
 			FalseExpr: falseValue,
+			Range:     p.spanFrom(expr.Span().Start),
+		}
+	}
+
+	return expr
+}
+
+// coalesce parses the '??' operator, which gives its right operand only
+// when the left one is nil.
+func (p *Parser) coalesce() ast.Expr {
+	var expr ast.Expr
+
+	expr = p.logicalOr()
+	for p.match(lexing.QuestionQuestion) {
+		operator := p.advance()
+		rightExpr := p.logicalOr()
+		expr = ast.LogicalExpr{
+			LeftExpr:  expr,
+			Operator:  operator,
+			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -380,6 +830,7 @@ func (p *Parser) logicalOr() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -397,6 +848,7 @@ func (p *Parser) logicalAnd() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -414,6 +866,7 @@ func (p *Parser) equality() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -431,6 +884,7 @@ func (p *Parser) comparison() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -448,6 +902,7 @@ func (p *Parser) term() ast.Expr {
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -458,13 +913,14 @@ func (p *Parser) factor() ast.Expr {
 	var expr ast.Expr
 
 	expr = p.unary()
-	for p.match(lexing.Star, lexing.Slash) {
+	for p.match(lexing.Star, lexing.Slash, lexing.Percent) {
 		operator := p.advance()
 		rightExpr := p.unary()
 		expr = ast.BinaryExpr{
 			LeftExpr:  expr,
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(expr.Span().Start),
 		}
 	}
 
@@ -478,46 +934,113 @@ func (p *Parser) unary() ast.Expr {
 		return ast.UnaryExpr{
 			Operator:  operator,
 			RightExpr: rightExpr,
+			Range:     p.spanFrom(operator.Start()),
+		}
+	}
+
+	if p.match(lexing.PlusPlus, lexing.MinusMinus) {
+		operator := p.advance()
+		variable := p.unary()
+		if !isAssignable(variable) {
+			p.parseError(operator, "invalid increment target")
+		}
+		return ast.IncrementExpr{
+			Variable: variable,
+			Operator: operator,
+			Prefix:   true,
+			Range:    p.spanFrom(operator.Start()),
 		}
 	}
 
-	return p.call()
+	return p.postfix()
 }
 
-func (p *Parser) call() ast.Expr {
-	expr := p.primary()
+func (p *Parser) postfix() ast.Expr {
+	expr := p.call()
 
-	if p.match(lexing.LeftParen) {
-		for p.match(lexing.LeftParen) {
-			p.advance()
-			expr = p.callArguments(expr)
+	if p.match(lexing.PlusPlus, lexing.MinusMinus) {
+		operator := p.advance()
+		if !isAssignable(expr) {
+			p.parseError(operator, "invalid increment target")
+		}
+		return ast.IncrementExpr{
+			Variable: expr,
+			Operator: operator,
+			Range:    p.spanFrom(expr.Span().Start),
 		}
-		return expr
 	}
 
-	if p.match(lexing.LeftBracket) {
-		for p.match(lexing.LeftBracket) {
+	return expr
+}
+
+// isAssignable reports whether the expression can be the target of an
+// assignment.
+func isAssignable(expr ast.Expr) bool {
+	switch expr.(type) {
+	case ast.VariableExpr:
+		return true
+	case ast.IndexExpr:
+		return !expr.(ast.IndexExpr).Optional
+	}
+	return false
+}
+
+func (p *Parser) call() ast.Expr {
+	expr := p.primary()
+
+	for {
+		switch {
+		case p.match(lexing.LeftParen):
+			p.advance()
+			expr = p.callArguments(expr, false)
+		case p.match(lexing.LeftBracket):
+			p.advance()
+			expr = p.arrayIndex(expr, false)
+		case p.match(lexing.QuestionBracket):
 			p.advance()
-			expr = p.arrayIndex(expr)
+			expr = p.arrayIndex(expr, true)
+		case p.match(lexing.Dot):
+			p.advance()
+			name := p.requireToken(lexing.Identifier, "expect property name after '.'")
+			expr = ast.GetExpr{
+				Object: expr,
+				Name:   name,
+				Range:  p.spanFrom(expr.Span().Start),
+			}
+		case p.match(lexing.QuestionDot):
+			p.advance()
+			if p.match(lexing.LeftParen) {
+				p.advance()
+				expr = p.callArguments(expr, true)
+				continue
+			}
+			name := p.requireToken(lexing.Identifier, "expect property name or '(' after '?.'")
+			expr = ast.GetExpr{
+				Object:   expr,
+				Name:     name,
+				Optional: true,
+				Range:    p.spanFrom(expr.Span().Start),
+			}
+		default:
+			return expr
 		}
-		return expr
 	}
-
-	return expr
 }
 
-func (p *Parser) callArguments(callee ast.Expr) ast.Expr {
+// callArguments parses the arguments of a call, an optional call gives nil
+// instead of calling a nil callee.
+func (p *Parser) callArguments(callee ast.Expr, optional bool) ast.Expr {
 	arguments := make([]ast.Expr, 0)
 
 	if !p.match(lexing.RightParen) {
-		arguments = append(arguments, p.assignment())
+		arguments = append(arguments, p.argument(arguments))
 		for p.match(lexing.Comma) {
 			p.advance()
 			if len(arguments) >= 255 {
 				p.parseError(p.peek(), "passed more than 255 arguments")
 				break
 			}
-			arguments = append(arguments, p.assignment())
+			arguments = append(arguments, p.argument(arguments))
 		}
 	}
 
@@ -526,44 +1049,87 @@ func (p *Parser) callArguments(callee ast.Expr) ast.Expr {
 		Callee:    callee,
 		Paren:     paren,
 		Arguments: arguments,
+		Optional:  optional,
+		Range:     p.spanFrom(callee.Span().Start),
+	}
+}
+
+// argument parses a call argument, either an element or a named argument
+// of the form name: value. Named arguments come after the others.
+func (p *Parser) argument(previous []ast.Expr) ast.Expr {
+	if p.match(lexing.Identifier) && p.peekNext().TokenType == lexing.Colon {
+		name := p.advance()
+		p.advance()
+		value := p.assignment()
+		return ast.NamedArgument{
+			Name:  name,
+			Value: value,
+			Range: p.spanFrom(name.Start()),
+		}
+	}
+
+	if len(previous) > 0 {
+		if _, ok := previous[len(previous)-1].(ast.NamedArgument); ok {
+			p.parseError(p.peek(), "positional argument follows named argument")
+		}
 	}
+	return p.element()
 }
 
-func (p *Parser) arrayIndex(array ast.Expr) ast.Expr {
+// element parses a call argument or an array literal element, which may
+// spread an array with '...'.
+func (p *Parser) element() ast.Expr {
+	if p.match(lexing.Ellipsis) {
+		ellipsis := p.advance()
+		expr := p.assignment()
+		return ast.SpreadExpr{
+			Ellipsis: ellipsis,
+			Expr:     expr,
+			Range:    p.spanFrom(ellipsis.Start()),
+		}
+	}
+	return p.assignment()
+}
+
+func (p *Parser) arrayIndex(array ast.Expr, optional bool) ast.Expr {
 	indexExpr := p.expression()
 	bracket := p.requireToken(lexing.RightBracket, "array index expression expect ']'")
 	return ast.IndexExpr{
 		Array:     array,
 		Bracket:   bracket,
 		IndexExpr: indexExpr,
+		Optional:  optional,
+		Range:     p.spanFrom(array.Span().Start),
 	}
 }
 
 func (p *Parser) primary() ast.Expr {
 	switch {
 	case p.match(lexing.False):
-		p.advance()
-		return ast.LiteralExpr{LiteralValue: false}
+		return ast.LiteralExpr{LiteralValue: false, Range: p.tokenSpan(p.advance())}
 	case p.match(lexing.True):
-		p.advance()
-		return ast.LiteralExpr{LiteralValue: true}
+		return ast.LiteralExpr{LiteralValue: true, Range: p.tokenSpan(p.advance())}
 	case p.match(lexing.Nil):
-		p.advance()
-		return ast.LiteralExpr{LiteralValue: nil}
+		return ast.LiteralExpr{LiteralValue: nil, Range: p.tokenSpan(p.advance())}
 	case p.match(lexing.Number, lexing.String):
-		astNode := ast.LiteralExpr{LiteralValue: p.peek().Literal}
+		astNode := ast.LiteralExpr{LiteralValue: p.peek().Literal, Range: p.tokenSpan(p.peek())}
 		p.advance()
 		return astNode
 	case p.match(lexing.LeftParen):
-		p.advance()
+		start := p.advance().Start()
 		expr := p.expression()
 		p.requireToken(lexing.RightParen, "expect ')' token after expression")
-		return ast.GroupingExpr{Expr: expr}
+		return ast.GroupingExpr{Expr: expr, Range: p.spanFrom(start)}
 	case p.match(lexing.LeftBracket):
 		p.advance()
 		return p.arrayElements()
+	case p.match(lexing.LeftBrace):
+		return p.mapEntries(p.advance())
+	case p.match(lexing.Match):
+		return p.matchExpr(p.advance())
 	case p.match(lexing.Identifier):
-		return ast.VariableExpr{Name: p.advance()}
+		name := p.advance()
+		return ast.VariableExpr{Name: name, Range: p.tokenSpan(name)}
 	}
 
 	p.parseError(p.peek(), "expect expression")
@@ -571,19 +1137,46 @@ func (p *Parser) primary() ast.Expr {
 }
 
 func (p *Parser) arrayElements() ast.Expr {
+	start := p.previous().Start()
 	elements := make([]ast.Expr, 0)
 
 	if !p.match(lexing.RightBracket) {
-		elements = append(elements, p.assignment())
+		elements = append(elements, p.element())
 		for p.match(lexing.Comma) {
 			p.advance()
-			elements = append(elements, p.assignment())
+			elements = append(elements, p.element())
 		}
 	}
 
 	p.requireToken(lexing.RightBracket, "array initializer expect ']'")
 	return ast.ArrayExpr{
 		Elements: elements,
+		Range:    p.spanFrom(start),
+	}
+}
+
+func (p *Parser) mapEntries(brace lexing.Token) ast.Expr {
+	var keys, values []ast.Expr
+
+	if !p.match(lexing.RightBrace) {
+		for {
+			keys = append(keys, p.assignment())
+			p.requireToken(lexing.Colon, "map entry expect ':' after key")
+			values = append(values, p.assignment())
+
+			if !p.match(lexing.Comma) {
+				break
+			}
+			p.advance()
+		}
+	}
+
+	p.requireToken(lexing.RightBrace, "map initializer expect '}'")
+	return ast.MapExpr{
+		Brace:  brace,
+		Keys:   keys,
+		Values: values,
+		Range:  p.spanFrom(brace.Start()),
 	}
 }
 
@@ -596,13 +1189,39 @@ func (p *Parser) requireToken(tokenType lexing.TokenType, message string) lexing
 	}
 }
 
+// Error is a syntax error reported at a particular token.
+type Error struct {
+	Token   lexing.Token
+	Message string
+
+	text string
+}
+
+func (e *Error) Error() string {
+	return e.text
+}
+
 func (p *Parser) parseError(token lexing.Token, message string) {
+	panic(p.newError(token, "error", message))
+}
+
+// warning reports a problem which doesn't prevent the program from running.
+func (p *Parser) warning(token lexing.Token, message string) {
+	p.Warnings = append(p.Warnings, p.newError(token, "warning", message))
+}
+
+func (p *Parser) newError(token lexing.Token, severity string, message string) *Error {
 	var buffer bytes.Buffer
-	buffer.WriteString(fmt.Sprintf("[ %d:%d ]: error: %s\n",
-		token.Line, token.Position, message))
+	buffer.WriteString(fmt.Sprintf("[ %d:%d ]: %s: %s\n",
+		token.Line, token.Position, severity, message))
+
+	var line string
+	if token.Line > 0 && token.Line <= len(p.lines) {
+		line = strings.TrimRight(p.lines[token.Line-1], "\r\n")
+	}
 
 	lineStr := strconv.Itoa(token.Line)
-	buffer.WriteString(fmt.Sprintf("      %d |         %s\n", token.Line, p.lines[token.Line-1]))
+	buffer.WriteString(fmt.Sprintf("      %d |         %s\n", token.Line, line))
 	buffer.WriteString(fmt.Sprintf("      "))
 	buffer.WriteString(strings.Repeat(" ", len(lineStr)))
 	buffer.WriteString(" |         ")
@@ -612,12 +1231,20 @@ func (p *Parser) parseError(token lexing.Token, message string) {
 		buffer.WriteString(fmt.Sprintf("%s\n", strings.Repeat("~", len(token.Lexeme)-1)))
 	}
 
-	panic(buffer.String())
+	return &Error{
+		Token:   token,
+		Message: message,
+		text:    buffer.String(),
+	}
 }
 
 func (p *Parser) parseRecoverFunc() {
 	if err := recover(); err != nil {
-		p.Errors = append(p.Errors, fmt.Errorf("%v", err))
+		if parseErr, ok := err.(*Error); ok {
+			p.Errors = append(p.Errors, parseErr)
+		} else {
+			p.Errors = append(p.Errors, fmt.Errorf("%v", err))
+		}
 		p.synchronize()
 	}
 }
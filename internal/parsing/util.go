@@ -1,6 +1,9 @@
 package parsing
 
-import "github.com/paw1a/golox/internal/lexing"
+import (
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+)
 
 func (p *Parser) match(tokenTypes ...lexing.TokenType) bool {
 	for _, tokenType := range tokenTypes {
@@ -25,6 +28,39 @@ func (p *Parser) peek() lexing.Token {
 	return p.tokens[p.current]
 }
 
+// peekNext returns the token after the current one.
+func (p *Parser) peekNext() lexing.Token {
+	if p.isEof() {
+		return p.peek()
+	}
+	return p.tokens[p.current+1]
+}
+
 func (p *Parser) isEof() bool {
 	return p.peek().TokenType == lexing.Eof
 }
+
+func (p *Parser) previous() lexing.Token {
+	if p.current == 0 {
+		return p.tokens[0]
+	}
+	return p.tokens[p.current-1]
+}
+
+// spanFrom returns the span from the given location up to the end of
+// the last consumed token.
+func (p *Parser) spanFrom(start lexing.Location) ast.Span {
+	return ast.Span{
+		File:  p.File,
+		Start: start,
+		End:   p.previous().End(),
+	}
+}
+
+func (p *Parser) tokenSpan(token lexing.Token) ast.Span {
+	return ast.Span{
+		File:  p.File,
+		Start: token.Start(),
+		End:   token.End(),
+	}
+}
@@ -0,0 +1,229 @@
+package profiler
+
+import (
+	"compress/gzip"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Field numbers of the messages in profile.proto of github.com/google/pprof.
+const (
+	profileSampleType    = 1
+	profileSample        = 2
+	profileLocation      = 4
+	profileFunction      = 5
+	profileStringTable   = 6
+	profileTimeNanos     = 9
+	profileDurationNanos = 10
+	profilePeriodType    = 11
+	profilePeriod        = 12
+
+	valueTypeType = 1
+	valueTypeUnit = 2
+
+	sampleLocationID = 1
+	sampleValue      = 2
+
+	locationID   = 1
+	locationLine = 4
+
+	lineFunctionID = 1
+	lineLine       = 2
+
+	functionID         = 1
+	functionName       = 2
+	functionSystemName = 3
+	functionFilename   = 4
+	functionStartLine  = 5
+)
+
+// WriteProfile writes the collected samples as a gzipped pprof profile
+// with two sample types: number of calls and time spent in nanoseconds.
+func (p *Profiler) WriteProfile(w io.Writer) error {
+	p.account()
+
+	table := newStringTable()
+	var profile buffer
+
+	for _, valueType := range [][2]string{{"calls", "count"}, {"time", "nanoseconds"}} {
+		var message buffer
+		message.int64(valueTypeType, table.index(valueType[0]))
+		message.int64(valueTypeUnit, table.index(valueType[1]))
+		profile.message(profileSampleType, message)
+	}
+
+	p.writeSamples(&profile, p.root)
+
+	locations := make([]*location, 0, len(p.locations))
+	for _, l := range p.locations {
+		locations = append(locations, l)
+	}
+	sort.Slice(locations, func(i, j int) bool {
+		return locations[i].id < locations[j].id
+	})
+	for _, l := range locations {
+		var line buffer
+		line.uint64(lineFunctionID, l.function.id)
+		line.int64(lineLine, int64(l.line))
+
+		var message buffer
+		message.uint64(locationID, l.id)
+		message.message(locationLine, line)
+		profile.message(profileLocation, message)
+	}
+
+	functions := make([]*function, 0, len(p.functions))
+	for _, f := range p.functions {
+		functions = append(functions, f)
+	}
+	sort.Slice(functions, func(i, j int) bool {
+		return functions[i].id < functions[j].id
+	})
+	for _, f := range functions {
+		var message buffer
+		message.uint64(functionID, f.id)
+		// pprof treats angle brackets as C++ template arguments and
+		// drops them, "<script>" would be shown without a name.
+		name := strings.Trim(f.name, "<>")
+		message.int64(functionName, table.index(name))
+		message.int64(functionSystemName, table.index(name))
+		message.int64(functionFilename, table.index(f.file))
+		message.int64(functionStartLine, int64(f.declared))
+		profile.message(profileFunction, message)
+	}
+
+	var periodType buffer
+	periodType.int64(valueTypeType, table.index("time"))
+	periodType.int64(valueTypeUnit, table.index("nanoseconds"))
+
+	for _, s := range table.strings {
+		profile.string(profileStringTable, s)
+	}
+	profile.int64(profileTimeNanos, p.start.UnixNano())
+	profile.int64(profileDurationNanos, time.Since(p.start).Nanoseconds())
+	profile.message(profilePeriodType, periodType)
+	profile.int64(profilePeriod, 1)
+
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.bytes); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func (p *Profiler) writeSamples(profile *buffer, n *node) {
+	if n.location != nil && (n.calls != 0 || n.nanos != 0) {
+		var ids []uint64
+		for at := n; at.location != nil; at = at.parent {
+			ids = append(ids, at.location.id)
+		}
+
+		var message buffer
+		message.packedUint64(sampleLocationID, ids)
+		message.packedInt64(sampleValue, []int64{n.calls, n.nanos})
+		profile.message(profileSample, message)
+	}
+
+	children := make([]*node, 0, len(n.children))
+	for _, child := range n.children {
+		children = append(children, child)
+	}
+	sort.Slice(children, func(i, j int) bool {
+		return children[i].location.id < children[j].location.id
+	})
+	for _, child := range children {
+		p.writeSamples(profile, child)
+	}
+}
+
+type stringTable struct {
+	strings []string
+	indices map[string]int64
+}
+
+// index returns the index of the string in the table, adding it if needed.
+// The first string of a pprof string table must be empty.
+func (t *stringTable) index(s string) int64 {
+	index, ok := t.indices[s]
+	if !ok {
+		index = int64(len(t.strings))
+		t.strings = append(t.strings, s)
+		t.indices[s] = index
+	}
+	return index
+}
+
+func newStringTable() *stringTable {
+	return &stringTable{
+		strings: []string{""},
+		indices: map[string]int64{"": 0},
+	}
+}
+
+// buffer is a minimal protocol buffers encoder, enough for profile.proto.
+type buffer struct {
+	bytes []byte
+}
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+func (b *buffer) varint(x uint64) {
+	for x >= 0x80 {
+		b.bytes = append(b.bytes, byte(x)|0x80)
+		x >>= 7
+	}
+	b.bytes = append(b.bytes, byte(x))
+}
+
+func (b *buffer) tag(field int, wireType int) {
+	b.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (b *buffer) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(x)
+}
+
+func (b *buffer) int64(field int, x int64) {
+	if x == 0 {
+		return
+	}
+	b.tag(field, wireVarint)
+	b.varint(uint64(x))
+}
+
+func (b *buffer) string(field int, s string) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(s)))
+	b.bytes = append(b.bytes, s...)
+}
+
+func (b *buffer) message(field int, message buffer) {
+	b.tag(field, wireBytes)
+	b.varint(uint64(len(message.bytes)))
+	b.bytes = append(b.bytes, message.bytes...)
+}
+
+func (b *buffer) packedUint64(field int, xs []uint64) {
+	var packed buffer
+	for _, x := range xs {
+		packed.varint(x)
+	}
+	b.message(field, packed)
+}
+
+func (b *buffer) packedInt64(field int, xs []int64) {
+	var packed buffer
+	for _, x := range xs {
+		packed.varint(uint64(x))
+	}
+	b.message(field, packed)
+}
@@ -0,0 +1,365 @@
+package profiler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/runtime"
+	"io/ioutil"
+	"reflect"
+	"testing"
+)
+
+func TestBufferEncoding(t *testing.T) {
+	tests := []struct {
+		name   string
+		encode func(b *buffer)
+		want   []byte
+	}{
+		{"varint 0", func(b *buffer) { b.varint(0) }, []byte{0x00}},
+		{"varint 127", func(b *buffer) { b.varint(127) }, []byte{0x7f}},
+		{"varint 128", func(b *buffer) { b.varint(128) }, []byte{0x80, 0x01}},
+		{"varint 300", func(b *buffer) { b.varint(300) }, []byte{0xac, 0x02}},
+		{"varint max", func(b *buffer) { b.varint(1<<64 - 1) },
+			[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}},
+		{"int64", func(b *buffer) { b.int64(1, 150) }, []byte{0x08, 0x96, 0x01}},
+		{"zero int64", func(b *buffer) { b.int64(1, 0) }, nil},
+		{"zero uint64", func(b *buffer) { b.uint64(3, 0) }, nil},
+		{"large field", func(b *buffer) { b.uint64(16, 1) }, []byte{0x80, 0x01, 0x01}},
+		{"string", func(b *buffer) { b.string(6, "ab") }, []byte{0x32, 0x02, 'a', 'b'}},
+		{"empty string", func(b *buffer) { b.string(6, "") }, []byte{0x32, 0x00}},
+		{"message", func(b *buffer) {
+			var inner buffer
+			inner.int64(1, 2)
+			b.message(4, inner)
+		}, []byte{0x22, 0x02, 0x08, 0x02}},
+		{"packed", func(b *buffer) { b.packedInt64(2, []int64{1, 0, 300}) },
+			[]byte{0x12, 0x04, 0x01, 0x00, 0xac, 0x02}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var b buffer
+			test.encode(&b)
+			if !bytes.Equal(b.bytes, test.want) {
+				t.Errorf("encoded % x, want % x", b.bytes, test.want)
+			}
+		})
+	}
+}
+
+// decoded is the part of a pprof profile the profiler writes.
+type decoded struct {
+	sampleTypes   [][2]string
+	samples       []decodedSample
+	locations     map[uint64]decodedLocation
+	functions     map[uint64]decodedFunction
+	strings       []string
+	timeNanos     int64
+	durationNanos int64
+	periodType    [2]string
+	period        int64
+}
+
+type decodedSample struct {
+	locations []uint64
+	values    []int64
+}
+
+type decodedLocation struct {
+	function uint64
+	line     int64
+}
+
+type decodedFunction struct {
+	name, systemName, filename string
+	startLine                  int64
+}
+
+type field struct {
+	number int
+	varint uint64
+	bytes  []byte
+}
+
+func readVarint(t *testing.T, data []byte) (uint64, []byte) {
+	var x uint64
+	for shift := uint(0); shift < 64; shift += 7 {
+		if len(data) == 0 {
+			t.Fatal("truncated varint")
+		}
+		b := data[0]
+		data = data[1:]
+		x |= uint64(b&0x7f) << shift
+		if b < 0x80 {
+			return x, data
+		}
+	}
+	t.Fatal("varint overflows 64 bits")
+	return 0, nil
+}
+
+func readFields(t *testing.T, data []byte) []field {
+	var fields []field
+	for len(data) > 0 {
+		var tag uint64
+		tag, data = readVarint(t, data)
+
+		f := field{number: int(tag >> 3)}
+		switch tag & 7 {
+		case wireVarint:
+			f.varint, data = readVarint(t, data)
+		case wireBytes:
+			var length uint64
+			length, data = readVarint(t, data)
+			if uint64(len(data)) < length {
+				t.Fatalf("field %d is truncated", f.number)
+			}
+			f.bytes, data = data[:length], data[length:]
+		default:
+			t.Fatalf("field %d has unexpected wire type %d", f.number, tag&7)
+		}
+		fields = append(fields, f)
+	}
+	return fields
+}
+
+func readPacked(t *testing.T, data []byte) []uint64 {
+	var xs []uint64
+	for len(data) > 0 {
+		var x uint64
+		x, data = readVarint(t, data)
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+// decode parses a gzipped profile, string table indices are resolved once
+// the whole profile has been read since the table comes last.
+func decode(t *testing.T, data []byte) decoded {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("profile is not gzipped: %v", err)
+	}
+	raw, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("can't decompress the profile: %v", err)
+	}
+
+	p := decoded{
+		locations: make(map[uint64]decodedLocation),
+		functions: make(map[uint64]decodedFunction),
+	}
+	var sampleTypes, periodType [][2]uint64
+	functionStrings := make(map[uint64][3]uint64)
+
+	valueType := func(data []byte) [2]uint64 {
+		var v [2]uint64
+		for _, f := range readFields(t, data) {
+			switch f.number {
+			case valueTypeType:
+				v[0] = f.varint
+			case valueTypeUnit:
+				v[1] = f.varint
+			}
+		}
+		return v
+	}
+
+	for _, f := range readFields(t, raw) {
+		switch f.number {
+		case profileSampleType:
+			sampleTypes = append(sampleTypes, valueType(f.bytes))
+		case profileSample:
+			var s decodedSample
+			for _, sf := range readFields(t, f.bytes) {
+				switch sf.number {
+				case sampleLocationID:
+					s.locations = readPacked(t, sf.bytes)
+				case sampleValue:
+					for _, v := range readPacked(t, sf.bytes) {
+						s.values = append(s.values, int64(v))
+					}
+				}
+			}
+			p.samples = append(p.samples, s)
+		case profileLocation:
+			var id uint64
+			var l decodedLocation
+			for _, lf := range readFields(t, f.bytes) {
+				switch lf.number {
+				case locationID:
+					id = lf.varint
+				case locationLine:
+					for _, line := range readFields(t, lf.bytes) {
+						switch line.number {
+						case lineFunctionID:
+							l.function = line.varint
+						case lineLine:
+							l.line = int64(line.varint)
+						}
+					}
+				}
+			}
+			p.locations[id] = l
+		case profileFunction:
+			var id uint64
+			var names [3]uint64
+			var fn decodedFunction
+			for _, ff := range readFields(t, f.bytes) {
+				switch ff.number {
+				case functionID:
+					id = ff.varint
+				case functionName:
+					names[0] = ff.varint
+				case functionSystemName:
+					names[1] = ff.varint
+				case functionFilename:
+					names[2] = ff.varint
+				case functionStartLine:
+					fn.startLine = int64(ff.varint)
+				}
+			}
+			functionStrings[id] = names
+			p.functions[id] = fn
+		case profileStringTable:
+			p.strings = append(p.strings, string(f.bytes))
+		case profileTimeNanos:
+			p.timeNanos = int64(f.varint)
+		case profileDurationNanos:
+			p.durationNanos = int64(f.varint)
+		case profilePeriodType:
+			periodType = append(periodType, valueType(f.bytes))
+		case profilePeriod:
+			p.period = int64(f.varint)
+		}
+	}
+
+	str := func(index uint64) string {
+		if index >= uint64(len(p.strings)) {
+			t.Fatalf("string index %d out of range", index)
+		}
+		return p.strings[index]
+	}
+	for _, v := range sampleTypes {
+		p.sampleTypes = append(p.sampleTypes, [2]string{str(v[0]), str(v[1])})
+	}
+	if len(periodType) != 1 {
+		t.Fatalf("profile has %d period types, want 1", len(periodType))
+	}
+	p.periodType = [2]string{str(periodType[0][0]), str(periodType[0][1])}
+	for id, names := range functionStrings {
+		fn := p.functions[id]
+		fn.name, fn.systemName, fn.filename = str(names[0]), str(names[1]), str(names[2])
+		p.functions[id] = fn
+	}
+	return p
+}
+
+const testProgram = `fun add(a, b) {
+  return a + b;
+}
+var total = 0;
+for (var i = 0; i < 3; i = i + 1) {
+  total = add(total, i);
+}
+print len([1, 2]);
+`
+
+func profile(t *testing.T, source string) decoded {
+	lexer := lexing.NewLexer(source)
+	lexer.ScanTokens()
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	statements := parser.Parse()
+	if len(lexer.Errors) != 0 || len(parser.Errors) != 0 {
+		t.Fatalf("invalid program: %v %v", lexer.Errors, parser.Errors)
+	}
+
+	p := NewProfiler("main.lox")
+	interpreter := runtime.NewInterpreter()
+	interpreter.SetOutput(ioutil.Discard)
+	interpreter.SetHook(p)
+	if err := interpreter.Interpret(context.Background(), statements); err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := p.WriteProfile(&out); err != nil {
+		t.Fatalf("WriteProfile: %v", err)
+	}
+	return decode(t, out.Bytes())
+}
+
+func TestWriteProfile(t *testing.T) {
+	p := profile(t, testProgram)
+
+	if len(p.strings) == 0 || p.strings[0] != "" {
+		t.Fatalf("string table %q doesn't start with an empty string", p.strings)
+	}
+	if want := [][2]string{{"calls", "count"}, {"time", "nanoseconds"}}; !reflect.DeepEqual(p.sampleTypes, want) {
+		t.Errorf("sample types = %v, want %v", p.sampleTypes, want)
+	}
+	if p.periodType != [2]string{"time", "nanoseconds"} || p.period != 1 {
+		t.Errorf("period = %d %v, want 1 [time nanoseconds]", p.period, p.periodType)
+	}
+	if p.timeNanos <= 0 || p.durationNanos <= 0 {
+		t.Errorf("time = %d, duration = %d, want both positive", p.timeNanos, p.durationNanos)
+	}
+
+	functions := make(map[string]decodedFunction)
+	for _, fn := range p.functions {
+		if fn.name != fn.systemName {
+			t.Errorf("function %q has system name %q", fn.name, fn.systemName)
+		}
+		functions[fn.name] = fn
+	}
+	wantFunctions := map[string]decodedFunction{
+		"script": {name: "script", systemName: "script", filename: "main.lox", startLine: 1},
+		"add":    {name: "add", systemName: "add", filename: "main.lox", startLine: 1},
+		"len":    {name: "len", systemName: "len", filename: "<native>"},
+	}
+	if !reflect.DeepEqual(functions, wantFunctions) {
+		t.Errorf("functions = %+v, want %+v", functions, wantFunctions)
+	}
+
+	calls := make(map[string]int64)
+	stacks := make(map[string]bool)
+	for _, sample := range p.samples {
+		if len(sample.values) != 2 || sample.values[0] < 0 || sample.values[1] < 0 {
+			t.Fatalf("sample %+v has invalid values", sample)
+		}
+
+		stack := ""
+		for _, id := range sample.locations {
+			l, ok := p.locations[id]
+			if !ok {
+				t.Fatalf("sample %+v refers to a missing location %d", sample, id)
+			}
+			fn, ok := p.functions[l.function]
+			if !ok {
+				t.Fatalf("location %d refers to a missing function %d", id, l.function)
+			}
+			stack += fmt.Sprintf("%s:%d ", fn.name, l.line)
+		}
+		root := p.locations[sample.locations[len(sample.locations)-1]]
+		if p.functions[root.function].name != "script" {
+			t.Errorf("sample stack %q doesn't start at the script", stack)
+		}
+
+		leaf := p.functions[p.locations[sample.locations[0]].function]
+		calls[leaf.name] += sample.values[0]
+		stacks[stack] = true
+	}
+
+	if calls["add"] != 3 || calls["len"] != 1 {
+		t.Errorf("calls = %v, want 3 calls of add and 1 of len", calls)
+	}
+	for _, stack := range []string{"add:2 script:6 ", "len:0 script:8 ", "script:5 "} {
+		if !stacks[stack] {
+			t.Errorf("no sample for the stack %q in %v", stack, stacks)
+		}
+	}
+}
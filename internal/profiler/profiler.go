@@ -0,0 +1,155 @@
+package profiler
+
+import (
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/runtime"
+	"time"
+)
+
+// Profiler is a runtime.CallHook measuring how much time is spent on every
+// line of every Lox function and how many times each function is called.
+// Samples are kept in a tree of call stacks, each node being a location,
+// so a statement only costs a clock read and a child lookup.
+type Profiler struct {
+	file  string
+	start time.Time
+	last  time.Time
+
+	root   *node
+	stack  []*node
+	nextID uint64
+
+	functions map[functionKey]*function
+	locations map[locationKey]*location
+}
+
+type function struct {
+	id       uint64
+	name     string
+	file     string
+	declared int
+}
+
+type location struct {
+	id       uint64
+	function *function
+	line     int
+}
+
+type functionKey struct {
+	name     string
+	declared int
+}
+
+type locationKey struct {
+	function *function
+	line     int
+}
+
+type node struct {
+	parent   *node
+	location *location
+	children map[*location]*node
+
+	calls int64
+	nanos int64
+}
+
+func (n *node) child(at *location) *node {
+	child, ok := n.children[at]
+	if !ok {
+		child = &node{
+			parent:   n,
+			location: at,
+			children: make(map[*location]*node),
+		}
+		n.children[at] = child
+	}
+	return child
+}
+
+func (p *Profiler) BeforeStmt(stmt ast.Stmt) {
+	p.account()
+
+	top := p.stack[len(p.stack)-1]
+	p.moveTo(top, stmt.Span().Start.Line)
+}
+
+func (p *Profiler) EnterCall(call runtime.Call) {
+	p.account()
+
+	caller := p.stack[len(p.stack)-1]
+	caller = p.moveTo(caller, call.Line)
+
+	file, line := p.file, call.Declared
+	if call.Native {
+		file, line = "<native>", 0
+	}
+	callee := caller.child(p.location(p.function(call.Function, file, call.Declared), line))
+	callee.calls++
+	p.stack = append(p.stack, callee)
+}
+
+func (p *Profiler) ExitCall(call runtime.Call) {
+	p.account()
+
+	if len(p.stack) > 1 {
+		p.stack = p.stack[:len(p.stack)-1]
+	}
+}
+
+// moveTo replaces the top of the stack with the node for another line of
+// the same function.
+func (p *Profiler) moveTo(top *node, line int) *node {
+	if top.location.line == line {
+		return top
+	}
+
+	moved := top.parent.child(p.location(top.location.function, line))
+	p.stack[len(p.stack)-1] = moved
+	return moved
+}
+
+// account charges the time elapsed since the previous event to the
+// location on top of the stack.
+func (p *Profiler) account() {
+	now := time.Now()
+	p.stack[len(p.stack)-1].nanos += now.Sub(p.last).Nanoseconds()
+	p.last = now
+}
+
+func (p *Profiler) function(name string, file string, declared int) *function {
+	key := functionKey{name: name, declared: declared}
+	f, ok := p.functions[key]
+	if !ok {
+		p.nextID++
+		f = &function{id: p.nextID, name: name, file: file, declared: declared}
+		p.functions[key] = f
+	}
+	return f
+}
+
+func (p *Profiler) location(f *function, line int) *location {
+	key := locationKey{function: f, line: line}
+	l, ok := p.locations[key]
+	if !ok {
+		l = &location{id: uint64(len(p.locations) + 1), function: f, line: line}
+		p.locations[key] = l
+	}
+	return l
+}
+
+func NewProfiler(file string) *Profiler {
+	p := &Profiler{
+		file:      file,
+		start:     time.Now(),
+		functions: make(map[functionKey]*function),
+		locations: make(map[locationKey]*location),
+	}
+	p.last = p.start
+
+	p.root = &node{children: make(map[*location]*node)}
+	script := p.root.child(p.location(p.function("<script>", file, 1), 1))
+	p.stack = []*node{script}
+	return p
+}
@@ -0,0 +1,409 @@
+package resolving
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+)
+
+type Severity int
+
+const (
+	Error Severity = iota
+	Warning
+)
+
+type Diagnostic struct {
+	Token    lexing.Token
+	Message  string
+	Severity Severity
+}
+
+func (d Diagnostic) Error() string {
+	return fmt.Sprintf("line %d | at '%s': %s", d.Token.Line, d.Token.Lexeme, d.Message)
+}
+
+type Kind int
+
+const (
+	Variable Kind = iota
+	Function
+	Parameter
+	Builtin
+	Constant
+)
+
+// Declaration is a single name introduced into some scope together with
+// every identifier in the source that refers to it.
+type Declaration struct {
+	Name       lexing.Token
+	Kind       Kind
+	Params     []lexing.Token
+	Function   *Declaration
+	References []lexing.Token
+}
+
+type Resolver struct {
+	Declarations []*Declaration
+	Diagnostics  []Diagnostic
+
+	scopes     []map[string]*Declaration
+	globals    map[string]*Declaration
+	builtins   map[string]*Declaration
+	byOffset   map[int]*Declaration
+	function   *Declaration
+	unresolved []lexing.Token
+	assigned   []lexing.Token
+}
+
+// Resolve binds every identifier in the statements to its declaration.
+// Globals may be referenced before they are declared, as they are looked
+// up at runtime, so global references are bound after the whole program
+// has been walked.
+func (r *Resolver) Resolve(statements []ast.Stmt) {
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+
+	for _, name := range r.unresolved {
+		if declaration, ok := r.globals[name.Lexeme]; ok {
+			r.reference(declaration, name)
+		} else if declaration, ok := r.builtins[name.Lexeme]; ok {
+			r.reference(declaration, name)
+		} else {
+			r.Diagnostics = append(r.Diagnostics, Diagnostic{
+				Token:    name,
+				Message:  fmt.Sprintf("undefined variable '%s'", name.Lexeme),
+				Severity: Warning,
+			})
+		}
+	}
+	r.unresolved = nil
+
+	for _, name := range r.assigned {
+		if declaration, ok := r.byOffset[name.Offset]; ok && declaration.Kind == Constant {
+			r.error(name, fmt.Sprintf("cannot assign to constant '%s'", name.Lexeme))
+		}
+	}
+	r.assigned = nil
+}
+
+// Errors returns the diagnostics which prevent the program from running.
+func (r *Resolver) Errors() []error {
+	var errors []error
+	for _, diagnostic := range r.Diagnostics {
+		if diagnostic.Severity == Error {
+			errors = append(errors, diagnostic)
+		}
+	}
+	return errors
+}
+
+// Lookup returns the declaration the identifier token at the given byte
+// offset declares or refers to.
+func (r *Resolver) Lookup(offset int) (*Declaration, bool) {
+	declaration, ok := r.byOffset[offset]
+	return declaration, ok
+}
+
+func (r *Resolver) resolveStmt(stmt ast.Stmt) {
+	switch stmt.(type) {
+	case ast.ExpressionStmt:
+		r.resolveExpr(stmt.(ast.ExpressionStmt).Expr)
+	case ast.PrintStmt:
+		r.resolveExpr(stmt.(ast.PrintStmt).Expr)
+	case ast.VarDeclarationStmt:
+		r.resolveVarDeclarationStmt(stmt.(ast.VarDeclarationStmt))
+	case ast.VarPatternStmt:
+		r.resolveExpr(stmt.(ast.VarPatternStmt).Initializer)
+		for _, name := range ast.PatternNames(stmt.(ast.VarPatternStmt).Pattern) {
+			r.declare(name, Variable)
+		}
+	case ast.FunDeclarationStmt:
+		r.resolveFunDeclarationStmt(stmt.(ast.FunDeclarationStmt))
+	case ast.BlockStmt:
+		r.beginScope()
+		r.resolveStmts(stmt.(ast.BlockStmt).Stmts)
+		r.endScope()
+	case ast.IfStmt:
+		r.resolveIfStmt(stmt.(ast.IfStmt))
+	case ast.ForStmt:
+		r.resolveForStmt(stmt.(ast.ForStmt))
+	case ast.ReturnStmt:
+		if stmt.(ast.ReturnStmt).Expr != nil {
+			r.resolveExpr(stmt.(ast.ReturnStmt).Expr)
+		}
+	case ast.MatchStmt:
+		r.resolveExpr(stmt.(ast.MatchStmt).Subject)
+		r.resolveArms(stmt.(ast.MatchStmt).Arms, func(body ast.Node) {
+			r.resolveStmt(body.(ast.Stmt))
+		})
+	}
+}
+
+func (r *Resolver) resolveStmts(statements []ast.Stmt) {
+	for _, stmt := range statements {
+		r.resolveStmt(stmt)
+	}
+}
+
+func (r *Resolver) resolveVarDeclarationStmt(stmt ast.VarDeclarationStmt) {
+	if stmt.Initializer != nil {
+		r.resolveExpr(stmt.Initializer)
+	}
+	if stmt.Const {
+		r.declare(stmt.Name, Constant)
+	} else {
+		r.declare(stmt.Name, Variable)
+	}
+}
+
+func (r *Resolver) resolveFunDeclarationStmt(stmt ast.FunDeclarationStmt) {
+	declaration := r.declare(stmt.Name, Function)
+	declaration.Params = stmt.Params
+	r.resolveFunction(declaration, stmt.Params, stmt.Defaults, stmt.Statement)
+}
+
+func (r *Resolver) resolveIfStmt(stmt ast.IfStmt) {
+	r.resolveExpr(stmt.ConditionExpr)
+	r.resolveStmt(stmt.IfStatement)
+	if stmt.ElseStatement != nil {
+		r.resolveStmt(stmt.ElseStatement)
+	}
+}
+
+func (r *Resolver) resolveForStmt(stmt ast.ForStmt) {
+	r.beginScope()
+	defer r.endScope()
+
+	if stmt.InitializerStmt != nil {
+		r.resolveStmt(stmt.InitializerStmt)
+	}
+	r.resolveExpr(stmt.ConditionExpr)
+	if stmt.IncrementExpr != nil {
+		r.resolveExpr(stmt.IncrementExpr)
+	}
+	r.resolveStmt(stmt.Statement)
+}
+
+func (r *Resolver) resolveFunction(declaration *Declaration, params []lexing.Token, defaults []ast.Expr,
+	body ast.BlockStmt) {
+	enclosingFunction := r.function
+	r.function = declaration
+	defer func() {
+		r.function = enclosingFunction
+	}()
+
+	r.beginScope()
+	for index, param := range params {
+		// A default value sees the parameters before it.
+		if index < len(defaults) && defaults[index] != nil {
+			r.resolveExpr(defaults[index])
+		}
+		r.declare(param, Parameter)
+	}
+	r.beginScope()
+	r.resolveStmts(body.Stmts)
+	r.endScope()
+	r.endScope()
+}
+
+func (r *Resolver) resolveExpr(expr ast.Expr) {
+	switch expr.(type) {
+	case ast.BinaryExpr:
+		r.resolveExpr(expr.(ast.BinaryExpr).LeftExpr)
+		r.resolveExpr(expr.(ast.BinaryExpr).RightExpr)
+	case ast.UnaryExpr:
+		r.resolveExpr(expr.(ast.UnaryExpr).RightExpr)
+	case ast.GroupingExpr:
+		r.resolveExpr(expr.(ast.GroupingExpr).Expr)
+	case ast.VariableExpr:
+		r.resolveName(expr.(ast.VariableExpr).Name)
+	case ast.AssignExpr:
+		r.resolveExpr(expr.(ast.AssignExpr).Initializer)
+		r.resolveExpr(expr.(ast.AssignExpr).Variable)
+		r.assign(expr.(ast.AssignExpr).Variable)
+	case ast.CompoundAssignExpr:
+		r.resolveExpr(expr.(ast.CompoundAssignExpr).Value)
+		r.resolveExpr(expr.(ast.CompoundAssignExpr).Variable)
+		r.assign(expr.(ast.CompoundAssignExpr).Variable)
+	case ast.IncrementExpr:
+		r.resolveExpr(expr.(ast.IncrementExpr).Variable)
+		r.assign(expr.(ast.IncrementExpr).Variable)
+	case ast.TernaryExpr:
+		r.resolveExpr(expr.(ast.TernaryExpr).Condition)
+		r.resolveExpr(expr.(ast.TernaryExpr).TrueExpr)
+		r.resolveExpr(expr.(ast.TernaryExpr).FalseExpr)
+	case ast.LogicalExpr:
+		r.resolveExpr(expr.(ast.LogicalExpr).LeftExpr)
+		r.resolveExpr(expr.(ast.LogicalExpr).RightExpr)
+	case ast.CallExpr:
+		r.resolveExpr(expr.(ast.CallExpr).Callee)
+		for _, argument := range expr.(ast.CallExpr).Arguments {
+			r.resolveExpr(argument)
+		}
+	case ast.ArrayPattern:
+		for _, element := range expr.(ast.ArrayPattern).Elements {
+			r.resolveExpr(element)
+		}
+		if expr.(ast.ArrayPattern).Rest != nil {
+			r.resolveExpr(expr.(ast.ArrayPattern).Rest)
+		}
+	case ast.NamedArgument:
+		r.resolveExpr(expr.(ast.NamedArgument).Value)
+	case ast.SpreadExpr:
+		r.resolveExpr(expr.(ast.SpreadExpr).Expr)
+	case ast.IndexExpr:
+		r.resolveExpr(expr.(ast.IndexExpr).Array)
+		r.resolveExpr(expr.(ast.IndexExpr).IndexExpr)
+	case ast.ArrayExpr:
+		for _, element := range expr.(ast.ArrayExpr).Elements {
+			r.resolveExpr(element)
+		}
+	case ast.MapExpr:
+		for index, key := range expr.(ast.MapExpr).Keys {
+			r.resolveExpr(key)
+			r.resolveExpr(expr.(ast.MapExpr).Values[index])
+		}
+	case ast.GetExpr:
+		r.resolveExpr(expr.(ast.GetExpr).Object)
+	case ast.LambdaExpr:
+		r.resolveLambdaExpr(expr.(ast.LambdaExpr))
+	case ast.MatchExpr:
+		r.resolveExpr(expr.(ast.MatchExpr).Subject)
+		r.resolveArms(expr.(ast.MatchExpr).Arms, func(body ast.Node) {
+			switch body.(type) {
+			case ast.BlockStmt:
+				r.resolveStmt(body.(ast.BlockStmt))
+			default:
+				r.resolveExpr(body.(ast.Expr))
+			}
+		})
+	}
+}
+
+// resolveArms resolves every arm in a scope holding the names bound by its
+// patterns.
+func (r *Resolver) resolveArms(arms []ast.MatchArm, resolveBody func(body ast.Node)) {
+	for _, arm := range arms {
+		r.beginScope()
+		for _, pattern := range arm.Patterns {
+			for _, name := range ast.PatternNames(pattern) {
+				r.declare(name, Variable)
+			}
+		}
+		if arm.Guard != nil {
+			r.resolveExpr(arm.Guard)
+		}
+		resolveBody(arm.Body)
+		r.endScope()
+	}
+}
+
+func (r *Resolver) resolveLambdaExpr(expr ast.LambdaExpr) {
+	lambda := &Declaration{
+		Name:     lexing.Token{TokenType: lexing.Fun, Lexeme: "fun"},
+		Kind:     Function,
+		Params:   expr.Params,
+		Function: r.function,
+	}
+	r.resolveFunction(lambda, expr.Params, expr.Defaults, expr.Statement)
+}
+
+func (r *Resolver) resolveName(name lexing.Token) {
+	for i := len(r.scopes) - 1; i >= 0; i-- {
+		if declaration, ok := r.scopes[i][name.Lexeme]; ok {
+			r.reference(declaration, name)
+			return
+		}
+	}
+
+	r.unresolved = append(r.unresolved, name)
+}
+
+// assign records the names an assignment target assigns to, they are
+// checked once every name is bound.
+func (r *Resolver) assign(target ast.Expr) {
+	switch target.(type) {
+	case ast.VariableExpr:
+		r.assigned = append(r.assigned, target.(ast.VariableExpr).Name)
+	case ast.ArrayPattern:
+		for _, element := range target.(ast.ArrayPattern).Elements {
+			r.assign(element)
+		}
+		if target.(ast.ArrayPattern).Rest != nil {
+			r.assign(target.(ast.ArrayPattern).Rest)
+		}
+	}
+}
+
+func (r *Resolver) declare(name lexing.Token, kind Kind) *Declaration {
+	scope := r.globals
+	if len(r.scopes) > 0 {
+		scope = r.scopes[len(r.scopes)-1]
+	}
+	if previous, ok := scope[name.Lexeme]; ok {
+		switch {
+		case previous.Kind == Constant:
+			r.error(name, fmt.Sprintf("cannot redeclare constant '%s'", name.Lexeme))
+		case kind == Constant:
+			r.error(name, fmt.Sprintf("cannot redeclare '%s' as constant", name.Lexeme))
+		}
+	}
+
+	declaration := &Declaration{
+		Name:     name,
+		Kind:     kind,
+		Function: r.function,
+	}
+	r.Declarations = append(r.Declarations, declaration)
+	r.byOffset[name.Offset] = declaration
+
+	if len(r.scopes) == 0 {
+		if _, ok := r.globals[name.Lexeme]; !ok {
+			r.globals[name.Lexeme] = declaration
+		}
+	} else {
+		r.scopes[len(r.scopes)-1][name.Lexeme] = declaration
+	}
+
+	return declaration
+}
+
+func (r *Resolver) error(token lexing.Token, message string) {
+	r.Diagnostics = append(r.Diagnostics, Diagnostic{
+		Token:    token,
+		Message:  message,
+		Severity: Error,
+	})
+}
+
+func (r *Resolver) reference(declaration *Declaration, name lexing.Token) {
+	declaration.References = append(declaration.References, name)
+	r.byOffset[name.Offset] = declaration
+}
+
+func (r *Resolver) beginScope() {
+	r.scopes = append(r.scopes, make(map[string]*Declaration))
+}
+
+func (r *Resolver) endScope() {
+	r.scopes = r.scopes[:len(r.scopes)-1]
+}
+
+func NewResolver(builtins ...string) *Resolver {
+	r := &Resolver{
+		globals:  make(map[string]*Declaration),
+		builtins: make(map[string]*Declaration),
+		byOffset: make(map[int]*Declaration),
+	}
+
+	for _, name := range builtins {
+		r.builtins[name] = &Declaration{
+			Name: lexing.Token{TokenType: lexing.Identifier, Lexeme: name},
+			Kind: Builtin,
+		}
+	}
+
+	return r
+}
@@ -0,0 +1,36 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// Array is the header of a Lox array, arrays are passed around as *Array
+// so that two arrays are the same array when their headers are.
+type Array struct {
+	Elements []interface{}
+
+	// frozen is set by freeze, the elements then can't be assigned to or
+	// appended to.
+	frozen bool
+}
+
+func NewArray(elements []interface{}) *Array {
+	return &Array{Elements: elements}
+}
+
+// String formats the elements the way fmt formats a slice.
+func (a *Array) String() string {
+	return fmt.Sprint(a.Elements)
+}
+
+func (a *Array) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(a.Elements); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buffer.Bytes(), []byte("\n")), nil
+}
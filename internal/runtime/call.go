@@ -1,7 +1,9 @@
 package runtime
 
 import (
+	"fmt"
 	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
 )
 
 type Caller interface {
@@ -9,34 +11,168 @@ type Caller interface {
 	ParametersCount() int
 }
 
-type Function struct {
-	Declaration ast.FunDeclarationStmt
-	Closure     *Environment
+// RangeCaller is implemented by callers accepting a range of argument
+// counts, max is -1 if there is no upper bound.
+type RangeCaller interface {
+	Arity() (min int, max int)
 }
 
-func (f Function) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	innerScope := NewEnvironment(f.Closure)
-	for i, argument := range arguments {
-		innerScope.define(f.Declaration.Params[i].Lexeme, argument)
+// arity returns the range of argument counts the callee accepts, a negative
+// ParametersCount accepts any number.
+func arity(callee Caller) (int, int) {
+	if rangeCaller, ok := callee.(RangeCaller); ok {
+		return rangeCaller.Arity()
+	}
+	if callee.ParametersCount() < 0 {
+		return 0, -1
 	}
+	return callee.ParametersCount(), callee.ParametersCount()
+}
 
-	enclosingEnv := interpreter.env
-	interpreter.env = innerScope
+// NamedCaller is implemented by callers whose arguments can be passed by
+// parameter name.
+type NamedCaller interface {
+	ParameterNames() []string
+}
+
+// missingArgument takes the place of a parameter skipped by named arguments,
+// so that its default value applies.
+type missingArgument struct{}
+
+type namedValue struct {
+	name  lexing.Token
+	value interface{}
+}
+
+// bindNamed puts the named arguments after the positional ones, at the
+// positions of the parameters they name.
+func bindNamed(paren lexing.Token, callee Caller, arguments []interface{}, named []namedValue) []interface{} {
+	namedCaller, ok := callee.(NamedCaller)
+	if !ok {
+		runtimeError(named[0].name, Repr(callee)+" does not accept named arguments")
+	}
+	names := namedCaller.ParameterNames()
+	min, max := arity(callee)
+
+	bound := append([]interface{}{}, arguments...)
+	for _, argument := range named {
+		index := -1
+		for i, name := range names {
+			if name == argument.name.Lexeme {
+				index = i
+			}
+		}
+
+		switch {
+		case index < 0:
+			runtimeError(argument.name, fmt.Sprintf("unknown parameter '%s'", argument.name.Lexeme))
+		case max < 0 && index == len(names)-1:
+			runtimeError(argument.name, fmt.Sprintf("rest parameter '%s' can't be passed by name", argument.name.Lexeme))
+		case index < len(bound) && bound[index] != (missingArgument{}):
+			runtimeError(argument.name, fmt.Sprintf("argument '%s' given more than once", argument.name.Lexeme))
+		}
+
+		for len(bound) <= index {
+			bound = append(bound, missingArgument{})
+		}
+		bound[index] = argument.value
+	}
+
+	for index := 0; index < min; index++ {
+		if index >= len(bound) || bound[index] == (missingArgument{}) {
+			runtimeError(paren, fmt.Sprintf("missing argument '%s'", names[index]))
+		}
+	}
+
+	// Natives know nothing of skipped parameters, they get nil.
+	switch callee.(type) {
+	case Function, LambdaFunction:
+	default:
+		for index := range bound {
+			if bound[index] == (missingArgument{}) {
+				bound[index] = nil
+			}
+		}
+	}
+	return bound
+}
+
+func checkArity(token lexing.Token, callee Caller, count int) {
+	min, max := arity(callee)
+	if count >= min && (max < 0 || count <= max) {
+		return
+	}
+
+	switch {
+	case min == max:
+		runtimeError(token, fmt.Sprintf("expect %d arguments, got %d", min, count))
+	case max < 0:
+		runtimeError(token, fmt.Sprintf("expect at least %d arguments, got %d", min, count))
+	default:
+		runtimeError(token, fmt.Sprintf("expect %d to %d arguments, got %d", min, max, count))
+	}
+}
+
+func formatArity(min int, max int) string {
+	switch {
+	case min == max:
+		return fmt.Sprintf("%d", min)
+	case max < 0:
+		return fmt.Sprintf("%d+", min)
+	}
+	return fmt.Sprintf("%d-%d", min, max)
+}
+
+// Call calls a function value from Go code such as natives taking callbacks,
+// counting towards the call depth and reported to the hook like a call in
+// the script made at the current call token. The interpreter state is
+// restored even if the call raises an error.
+func (i *Interpreter) Call(callee Caller, arguments ...interface{}) interface{} {
+	checkArity(i.callToken, callee, len(arguments))
+
+	env := i.env
 	defer func() {
-		interpreter.env = enclosingEnv
+		i.env = env
 	}()
 
-	interpreter.executeBlockStmt(f.Declaration.Statement)
-	if interpreter.returnContext.returnFlag {
-		interpreter.returnContext.returnFlag = false
-		return interpreter.returnContext.returnValue
+	i.enterCall(i.callToken)
+	defer i.exitCall(i.callToken)
+	return i.invoke(callee, i.callToken, arguments)
+}
+
+// invoke calls the function with call as the token the call is reported
+// at, notifying the hook.
+func (i *Interpreter) invoke(function Caller, call lexing.Token, arguments []interface{}) interface{} {
+	if i.hook != nil {
+		i.pushFrame(function, call)
+		defer i.popFrame(function)
 	}
 
-	return nil
+	i.callToken = call
+	return function.Call(i, arguments)
+}
+
+type Function struct {
+	Declaration ast.FunDeclarationStmt
+	Closure     *Environment
+}
+
+func (f Function) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	declaration := f.Declaration
+	return callFunction(interpreter, f.Closure, declaration.Params, declaration.Defaults, declaration.Rest,
+		declaration.Statement, arguments)
 }
 
 func (f Function) ParametersCount() int {
-	return len(f.Declaration.Params)
+	return parametersCount(f.Arity())
+}
+
+func (f Function) Arity() (int, int) {
+	return parametersArity(f.Declaration.Params, f.Declaration.Defaults, f.Declaration.Rest)
+}
+
+func (f Function) ParameterNames() []string {
+	return parameterNames(f.Declaration.Params)
 }
 
 type LambdaFunction struct {
@@ -45,18 +181,53 @@ type LambdaFunction struct {
 }
 
 func (f LambdaFunction) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	innerScope := NewEnvironment(f.Closure)
-	for i, argument := range arguments {
-		innerScope.define(f.LambdaExpr.Params[i].Lexeme, argument)
-	}
+	lambda := f.LambdaExpr
+	return callFunction(interpreter, f.Closure, lambda.Params, lambda.Defaults, lambda.Rest,
+		lambda.Statement, arguments)
+}
+
+func (f LambdaFunction) ParametersCount() int {
+	return parametersCount(f.Arity())
+}
 
+func (f LambdaFunction) Arity() (int, int) {
+	return parametersArity(f.LambdaExpr.Params, f.LambdaExpr.Defaults, f.LambdaExpr.Rest)
+}
+
+func (f LambdaFunction) ParameterNames() []string {
+	return parameterNames(f.LambdaExpr.Params)
+}
+
+// callFunction binds the arguments to the parameters in a new scope of the
+// closure and executes the body. Default values are evaluated in that scope,
+// so they can refer to the parameters before them.
+func callFunction(interpreter *Interpreter, closure *Environment, params []lexing.Token, defaults []ast.Expr,
+	rest bool, body ast.BlockStmt, arguments []interface{}) interface{} {
 	enclosingEnv := interpreter.env
-	interpreter.env = innerScope
+	interpreter.env = NewEnvironment(closure)
 	defer func() {
 		interpreter.env = enclosingEnv
 	}()
 
-	interpreter.executeBlockStmt(f.LambdaExpr.Statement)
+	for index, param := range params {
+		var value interface{}
+		switch {
+		case rest && index == len(params)-1:
+			remaining := make([]interface{}, 0)
+			if index < len(arguments) {
+				remaining = append(remaining, arguments[index:]...)
+			}
+			interpreter.allocate(param.Line, len(remaining)*valueSize)
+			value = NewArray(remaining)
+		case index < len(arguments) && arguments[index] != (missingArgument{}):
+			value = arguments[index]
+		case index < len(defaults) && defaults[index] != nil:
+			value = interpreter.Evaluate(defaults[index])
+		}
+		interpreter.env.define(param.Lexeme, value)
+	}
+
+	interpreter.executeBlockStmt(body)
 	if interpreter.returnContext.returnFlag {
 		interpreter.returnContext.returnFlag = false
 		return interpreter.returnContext.returnValue
@@ -65,6 +236,34 @@ func (f LambdaFunction) Call(interpreter *Interpreter, arguments []interface{})
 	return nil
 }
 
-func (f LambdaFunction) ParametersCount() int {
-	return len(f.LambdaExpr.Params)
+// parametersArity counts the parameters before the first one with a default
+// value or the rest parameter as required.
+func parametersArity(params []lexing.Token, defaults []ast.Expr, rest bool) (int, int) {
+	max := len(params)
+	if rest {
+		max = -1
+	}
+
+	min := 0
+	for min < len(params) && (min >= len(defaults) || defaults[min] == nil) && !(rest && min == len(params)-1) {
+		min++
+	}
+	return min, max
+}
+
+func parameterNames(params []lexing.Token) []string {
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Lexeme
+	}
+	return names
+}
+
+// parametersCount is the ParametersCount of a function with the arity,
+// which is -1 unless it takes an exact number of arguments.
+func parametersCount(min int, max int) int {
+	if min != max {
+		return -1
+	}
+	return min
 }
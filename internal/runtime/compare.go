@@ -0,0 +1,267 @@
+package runtime
+
+import (
+	"github.com/paw1a/golox/internal/lexing"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Equal implements the == operator. Nil, booleans, numbers and strings are
+// equal by value, arrays, maps, functions and natives by identity. Values
+// of different types are never equal.
+func Equal(a interface{}, b interface{}) bool {
+	switch a.(type) {
+	case map[string]interface{}:
+		other, ok := b.(map[string]interface{})
+		return ok && reflect.ValueOf(a).Pointer() == reflect.ValueOf(other).Pointer()
+	case Function:
+		other, ok := b.(Function)
+		return ok && a.(Function).Declaration.Span() == other.Declaration.Span() &&
+			a.(Function).Closure == other.Closure
+	case LambdaFunction:
+		other, ok := b.(LambdaFunction)
+		return ok && a.(LambdaFunction).LambdaExpr.Span() == other.LambdaExpr.Span() &&
+			a.(LambdaFunction).Closure == other.Closure
+	}
+
+	if reflect.TypeOf(a) != reflect.TypeOf(b) {
+		return false
+	}
+	if a == nil {
+		return true
+	}
+	return reflect.TypeOf(a).Comparable() && a == b
+}
+
+// DeepEqual compares arrays element by element and maps entry by entry,
+// other values as Equal does.
+func DeepEqual(a interface{}, b interface{}) bool {
+	return deepEqual(a, b, make(map[[2]uintptr]bool))
+}
+
+// deepEqual assumes the pairs of containers being compared are equal, so
+// cyclic values terminate.
+func deepEqual(a interface{}, b interface{}, comparing map[[2]uintptr]bool) bool {
+	if Equal(a, b) {
+		return true
+	}
+
+	switch a.(type) {
+	case *Array:
+		array := a.(*Array)
+		other, ok := b.(*Array)
+		if !ok || len(array.Elements) != len(other.Elements) {
+			return false
+		}
+		pair := [2]uintptr{reflect.ValueOf(array).Pointer(), reflect.ValueOf(other).Pointer()}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		for i, element := range array.Elements {
+			if !deepEqual(element, other.Elements[i], comparing) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		entries := a.(map[string]interface{})
+		other, ok := b.(map[string]interface{})
+		if !ok || len(entries) != len(other) {
+			return false
+		}
+		pair := [2]uintptr{reflect.ValueOf(a).Pointer(), reflect.ValueOf(other).Pointer()}
+		if comparing[pair] {
+			return true
+		}
+		comparing[pair] = true
+		for key, value := range entries {
+			otherValue, found := other[key]
+			if !found || !deepEqual(value, otherValue, comparing) {
+				return false
+			}
+		}
+		return true
+	}
+
+	return false
+}
+
+// Compare orders any two values, returning a negative number, zero or a
+// positive number. Values of different types are ordered by type: nil,
+// booleans, numbers, strings, arrays, maps, then everything else by its
+// formatted form. NaN is ordered before the other numbers. Arrays and maps
+// containing themselves can't be ordered, comparing them raises a runtime
+// error at the token.
+func Compare(token lexing.Token, a interface{}, b interface{}) int {
+	return compare(token, a, b, make(map[[2]uintptr]bool))
+}
+
+// compare holds in comparing the pairs of containers being compared, to
+// detect cycles.
+func compare(token lexing.Token, a interface{}, b interface{}, comparing map[[2]uintptr]bool) int {
+	if rankA, rankB := typeRank(a), typeRank(b); rankA != rankB {
+		return rankA - rankB
+	}
+
+	switch a.(type) {
+	case nil:
+		return 0
+	case bool:
+		return compareBool(a.(bool), b.(bool))
+	case float64:
+		return compareNumber(a.(float64), b.(float64))
+	case string:
+		return strings.Compare(a.(string), b.(string))
+	case *Array:
+		if Equal(a, b) {
+			return 0
+		}
+		array, other := a.(*Array), b.(*Array)
+		defer enterCompare(token, array, other, comparing)()
+		for i := 0; i < len(array.Elements) && i < len(other.Elements); i++ {
+			if order := compare(token, array.Elements[i], other.Elements[i], comparing); order != 0 {
+				return order
+			}
+		}
+		return len(array.Elements) - len(other.Elements)
+	case map[string]interface{}:
+		if Equal(a, b) {
+			return 0
+		}
+		entries, other := a.(map[string]interface{}), b.(map[string]interface{})
+		defer enterCompare(token, entries, other, comparing)()
+		keys, otherKeys := sortedKeys(entries), sortedKeys(other)
+		for i := 0; i < len(keys) && i < len(otherKeys); i++ {
+			if order := strings.Compare(keys[i], otherKeys[i]); order != 0 {
+				return order
+			}
+			if order := compare(token, entries[keys[i]], other[otherKeys[i]], comparing); order != 0 {
+				return order
+			}
+		}
+		return len(keys) - len(otherKeys)
+	}
+
+	return strings.Compare(Repr(a), Repr(b))
+}
+
+// enterCompare marks the pair of containers as being compared, raising a
+// runtime error if it already is, and returns the function unmarking it.
+func enterCompare(token lexing.Token, a interface{}, b interface{}, comparing map[[2]uintptr]bool) func() {
+	pair := [2]uintptr{reflect.ValueOf(a).Pointer(), reflect.ValueOf(b).Pointer()}
+	if comparing[pair] {
+		runtimeError(token, "cannot compare values containing themselves")
+	}
+	comparing[pair] = true
+	return func() {
+		delete(comparing, pair)
+	}
+}
+
+func typeRank(value interface{}) int {
+	switch value.(type) {
+	case nil:
+		return 0
+	case bool:
+		return 1
+	case float64:
+		return 2
+	case string:
+		return 3
+	case *Array:
+		return 4
+	case map[string]interface{}:
+		return 5
+	}
+	return 6
+}
+
+func compareBool(a bool, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case b:
+		return -1
+	}
+	return 1
+}
+
+func compareNumber(a float64, b float64) int {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return compareBool(!math.IsNaN(a), !math.IsNaN(b))
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+type DeepEqualFunc struct {
+}
+
+func (f DeepEqualFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	return DeepEqual(arguments[0], arguments[1])
+}
+
+func (f DeepEqualFunc) ParametersCount() int {
+	return 2
+}
+
+func (f DeepEqualFunc) ParameterNames() []string {
+	return []string{"a", "b"}
+}
+
+type SortFunc struct {
+}
+
+// Call returns a sorted copy of the array, in the order of Compare or of the
+// optional compare function returning a negative number, zero or a positive
+// number. The sort is stable.
+func (f SortFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	array, ok := arguments[0].(*Array)
+	if !ok {
+		runtimeError(interpreter.callToken, "sort func expect array argument first")
+	}
+
+	token := interpreter.callToken
+	order := func(a interface{}, b interface{}) int {
+		return Compare(token, a, b)
+	}
+	if len(arguments) == 2 && arguments[1] != nil {
+		function, ok := arguments[1].(Caller)
+		if !ok {
+			runtimeError(interpreter.callToken, "sort func expect compare function second")
+		}
+		order = func(a interface{}, b interface{}) int {
+			result, ok := interpreter.Call(function, a, b).(float64)
+			if !ok {
+				runtimeError(token, "sort compare function must return a number")
+			}
+			return compareNumber(result, 0)
+		}
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(array.Elements)*valueSize)
+	sorted := append([]interface{}{}, array.Elements...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return order(sorted[i], sorted[j]) < 0
+	})
+	return NewArray(sorted)
+}
+
+func (f SortFunc) ParametersCount() int {
+	return -1
+}
+
+func (f SortFunc) Arity() (int, int) {
+	return 1, 2
+}
+
+func (f SortFunc) ParameterNames() []string {
+	return []string{"array", "compare"}
+}
@@ -3,6 +3,7 @@ package runtime
 import (
 	"fmt"
 	"github.com/paw1a/golox/internal/lexing"
+	"sort"
 )
 
 type Environment struct {
@@ -10,13 +11,26 @@ type Environment struct {
 	objects   map[string]interface{}
 }
 
+// constant wraps the value of a name declared with const, so that it is
+// stored with the name without an extra map per environment.
+type constant struct {
+	value interface{}
+}
+
 func (e Environment) define(name string, value interface{}) {
 	e.objects[name] = value
 }
 
+func (e Environment) defineConstant(name string, value interface{}) {
+	e.objects[name] = constant{value: value}
+}
+
 func (e Environment) get(name lexing.Token) interface{} {
 	value, ok := e.objects[name.Lexeme]
 	if ok {
+		if c, isConstant := value.(constant); isConstant {
+			return c.value
+		}
 		return value
 	}
 
@@ -29,7 +43,10 @@ func (e Environment) get(name lexing.Token) interface{} {
 }
 
 func (e Environment) assign(name lexing.Token, value interface{}) {
-	if _, ok := e.objects[name.Lexeme]; ok {
+	if old, ok := e.objects[name.Lexeme]; ok {
+		if _, isConstant := old.(constant); isConstant {
+			runtimeError(name, fmt.Sprintf("cannot assign to constant '%s'", name.Lexeme))
+		}
 		e.objects[name.Lexeme] = value
 		return
 	}
@@ -42,6 +59,28 @@ func (e Environment) assign(name lexing.Token, value interface{}) {
 	runtimeError(name, fmt.Sprintf("undefined variable '%s'", name.Lexeme))
 }
 
+func (e Environment) Enclosing() *Environment {
+	return e.enclosing
+}
+
+// Names returns the names defined directly in this environment, sorted.
+func (e Environment) Names() []string {
+	names := make([]string, 0, len(e.objects))
+	for name := range e.objects {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (e Environment) Lookup(name string) (interface{}, bool) {
+	value, ok := e.objects[name]
+	if c, isConstant := value.(constant); isConstant {
+		return c.value, ok
+	}
+	return value, ok
+}
+
 func NewEnvironment(enclosing *Environment) *Environment {
 	return &Environment{
 		enclosing: enclosing,
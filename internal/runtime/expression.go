@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/paw1a/golox/internal/ast"
 	"github.com/paw1a/golox/internal/lexing"
+	"math"
 )
 
 func (i *Interpreter) Evaluate(expr ast.Expr) interface{} {
@@ -20,6 +21,10 @@ func (i *Interpreter) Evaluate(expr ast.Expr) interface{} {
 		return i.evaluateVariableExpr(expr.(ast.VariableExpr))
 	case ast.AssignExpr:
 		return i.evaluateAssignExpr(expr.(ast.AssignExpr))
+	case ast.CompoundAssignExpr:
+		return i.evaluateCompoundAssignExpr(expr.(ast.CompoundAssignExpr))
+	case ast.IncrementExpr:
+		return i.evaluateIncrementExpr(expr.(ast.IncrementExpr))
 	case ast.TernaryExpr:
 		return i.evaluateTernaryExpr(expr.(ast.TernaryExpr))
 	case ast.LogicalExpr:
@@ -30,8 +35,14 @@ func (i *Interpreter) Evaluate(expr ast.Expr) interface{} {
 		return i.evaluateIndexExpr(expr.(ast.IndexExpr))
 	case ast.ArrayExpr:
 		return i.evaluateArrayExpr(expr.(ast.ArrayExpr))
+	case ast.MapExpr:
+		return i.evaluateMapExpr(expr.(ast.MapExpr))
+	case ast.GetExpr:
+		return i.evaluateGetExpr(expr.(ast.GetExpr))
 	case ast.LambdaExpr:
 		return i.evaluateLambdaExpr(expr.(ast.LambdaExpr))
+	case ast.MatchExpr:
+		return i.evaluateMatchExpr(expr.(ast.MatchExpr))
 	default:
 		runtimeError(lexing.Token{}, "invalid ast type")
 	}
@@ -44,32 +55,8 @@ func (i *Interpreter) evaluateBinaryExpr(expr ast.BinaryExpr) interface{} {
 	rightValue := i.Evaluate(expr.RightExpr)
 
 	switch expr.Operator.TokenType {
-	case lexing.Plus:
-		switch {
-		case isNumber(leftValue) && isNumber(rightValue):
-			return leftValue.(float64) + rightValue.(float64)
-		case isString(leftValue) && isString(rightValue):
-			return leftValue.(string) + rightValue.(string)
-		default:
-			runtimeError(expr.Operator, "number or string operands expected")
-		}
-	case lexing.Minus:
-		requireNumberOperand(expr.Operator, leftValue)
-		requireNumberOperand(expr.Operator, rightValue)
-		return leftValue.(float64) - rightValue.(float64)
-	case lexing.Star:
-		requireNumberOperand(expr.Operator, leftValue)
-		requireNumberOperand(expr.Operator, rightValue)
-		return leftValue.(float64) * rightValue.(float64)
-	case lexing.Slash:
-		requireNumberOperand(expr.Operator, leftValue)
-		requireNumberOperand(expr.Operator, rightValue)
-
-		if rightValue.(float64) == 0 {
-			runtimeError(expr.Operator, "zero division")
-		}
-
-		return leftValue.(float64) / rightValue.(float64)
+	case lexing.Plus, lexing.Minus, lexing.Star, lexing.Slash, lexing.Percent:
+		return i.arithmetic(expr.Operator, expr.Operator.TokenType, leftValue, rightValue)
 	case lexing.Less:
 		switch {
 		case isNumber(leftValue) && isNumber(rightValue):
@@ -107,33 +94,51 @@ func (i *Interpreter) evaluateBinaryExpr(expr ast.BinaryExpr) interface{} {
 			runtimeError(expr.Operator, "number or string operands expected")
 		}
 	case lexing.EqualEqual:
-		switch {
-		case leftValue == nil && rightValue == nil:
-			return true
-		case leftValue == nil || rightValue == nil:
-			return false
-		case isNumber(leftValue) && isNumber(rightValue):
-			return leftValue.(float64) == rightValue.(float64)
-		case isString(leftValue) && isString(rightValue):
-			return leftValue.(string) == rightValue.(string)
-		default:
-			runtimeError(expr.Operator, "number, string or nil operands expected")
-		}
+		return Equal(leftValue, rightValue)
 	case lexing.BangEqual:
+		return !Equal(leftValue, rightValue)
+	case lexing.Comma:
+		return rightValue
+	}
+
+	return nil
+}
+
+// arithmetic applies the operation of the token type, the operator token
+// locates errors. It is shared by binary and compound assignment operators.
+func (i *Interpreter) arithmetic(operator lexing.Token, operation lexing.TokenType,
+	leftValue interface{}, rightValue interface{}) interface{} {
+	switch operation {
+	case lexing.Plus:
 		switch {
-		case leftValue == nil && rightValue == nil:
-			return false
-		case leftValue == nil || rightValue == nil:
-			return true
 		case isNumber(leftValue) && isNumber(rightValue):
-			return leftValue.(float64) != rightValue.(float64)
+			return leftValue.(float64) + rightValue.(float64)
 		case isString(leftValue) && isString(rightValue):
-			return leftValue.(string) != rightValue.(string)
+			i.allocate(operator.Line, len(leftValue.(string))+len(rightValue.(string)))
+			return leftValue.(string) + rightValue.(string)
 		default:
-			runtimeError(expr.Operator, "number, string or nil operands expected")
+			runtimeError(operator, "number or string operands expected")
 		}
-	case lexing.Comma:
-		return rightValue
+	case lexing.Minus:
+		requireNumberOperand(operator, leftValue)
+		requireNumberOperand(operator, rightValue)
+		return leftValue.(float64) - rightValue.(float64)
+	case lexing.Star:
+		requireNumberOperand(operator, leftValue)
+		requireNumberOperand(operator, rightValue)
+		return leftValue.(float64) * rightValue.(float64)
+	case lexing.Slash, lexing.Percent:
+		requireNumberOperand(operator, leftValue)
+		requireNumberOperand(operator, rightValue)
+
+		if rightValue.(float64) == 0 {
+			runtimeError(operator, "zero division")
+		}
+
+		if operation == lexing.Percent {
+			return math.Mod(leftValue.(float64), rightValue.(float64))
+		}
+		return leftValue.(float64) / rightValue.(float64)
 	}
 
 	return nil
@@ -144,6 +149,7 @@ func (i *Interpreter) evaluateUnaryExpr(expr ast.UnaryExpr) interface{} {
 
 	switch expr.Operator.TokenType {
 	case lexing.Minus:
+		requireNumberOperand(expr.Operator, value)
 		return -value.(float64)
 	case lexing.Bang:
 		return !isTruthy(value)
@@ -166,30 +172,137 @@ func (i *Interpreter) evaluateVariableExpr(expr ast.VariableExpr) interface{} {
 
 func (i *Interpreter) evaluateAssignExpr(expr ast.AssignExpr) interface{} {
 	value := i.Evaluate(expr.Initializer)
-	switch expr.Variable.(type) {
+	i.assign(expr.Variable, value)
+	return value
+}
+
+func (i *Interpreter) assign(target ast.Expr, value interface{}) {
+	switch target.(type) {
 	case ast.IndexExpr:
-		array := i.Evaluate(expr.Variable.(ast.IndexExpr).Array).([]interface{})
-		indexValue := i.Evaluate(expr.Variable.(ast.IndexExpr).IndexExpr)
-		var index int
-		if isNumber(indexValue) {
-			index = int(indexValue.(float64))
-		} else {
-			runtimeError(expr.Variable.(ast.IndexExpr).Bracket, "index must be integer number")
+		i.assignIndex(target.(ast.IndexExpr), value)
+	case ast.VariableExpr:
+		i.env.assign(target.(ast.VariableExpr).Name, value)
+	case ast.ArrayPattern:
+		i.destructure(target, value, i.assign)
+	}
+}
+
+// destructure matches the value against the pattern and binds every
+// variable or index target in the pattern to its part of the value.
+func (i *Interpreter) destructure(pattern ast.Expr, value interface{},
+	bind func(target ast.Expr, value interface{})) {
+	switch pattern.(type) {
+	case ast.ArrayPattern:
+		arrayPattern := pattern.(ast.ArrayPattern)
+		header, ok := value.(*Array)
+		if !ok {
+			runtimeError(arrayPattern.Bracket, "array pattern expect array, got "+Repr(value))
 		}
+		array := header.Elements
 
-		if index >= len(array) {
-			runtimeError(expr.Variable.(ast.IndexExpr).Bracket,
-				fmt.Sprintf("index %d out of range in array with len %d", index, len(array)))
+		switch {
+		case arrayPattern.Rest == nil && len(array) != len(arrayPattern.Elements):
+			runtimeError(arrayPattern.Bracket, fmt.Sprintf("array pattern expect %d elements, got %d",
+				len(arrayPattern.Elements), len(array)))
+		case arrayPattern.Rest != nil && len(array) < len(arrayPattern.Elements):
+			runtimeError(arrayPattern.Bracket, fmt.Sprintf("array pattern expect at least %d elements, got %d",
+				len(arrayPattern.Elements), len(array)))
 		}
-		array[index] = value
-	case ast.VariableExpr:
-		i.env.assign(expr.Variable.(ast.VariableExpr).Name, value)
+
+		for index, element := range arrayPattern.Elements {
+			i.destructure(element, array[index], bind)
+		}
+		if arrayPattern.Rest != nil {
+			rest := array[len(arrayPattern.Elements):]
+			i.allocate(arrayPattern.Bracket.Line, len(rest)*valueSize)
+			i.destructure(arrayPattern.Rest, NewArray(append([]interface{}{}, rest...)), bind)
+		}
+	case ast.MapPattern:
+		mapPattern := pattern.(ast.MapPattern)
+		entries, ok := value.(map[string]interface{})
+		if !ok {
+			runtimeError(mapPattern.Brace, "map pattern expect map, got "+Repr(value))
+		}
+
+		for index, keyToken := range mapPattern.Keys {
+			key := keyToken.Lexeme
+			if keyToken.TokenType == lexing.String {
+				key = keyToken.Literal.(string)
+			}
+			entry, found := entries[key]
+			if !found {
+				runtimeError(keyToken, fmt.Sprintf("map pattern key '%s' is missing", key))
+			}
+			i.destructure(mapPattern.Values[index], entry, bind)
+		}
+	default:
+		bind(pattern, value)
 	}
+}
+
+func (i *Interpreter) assignIndex(expr ast.IndexExpr, value interface{}) {
+	i.setIndex(expr.Bracket, i.Evaluate(expr.Array), i.Evaluate(expr.IndexExpr), value)
+}
+
+// compoundOperations maps the compound assignment and increment operators
+// to the arithmetic they apply.
+var compoundOperations = map[lexing.TokenType]lexing.TokenType{
+	lexing.PlusEqual:    lexing.Plus,
+	lexing.MinusEqual:   lexing.Minus,
+	lexing.StarEqual:    lexing.Star,
+	lexing.SlashEqual:   lexing.Slash,
+	lexing.PercentEqual: lexing.Percent,
+	lexing.PlusPlus:     lexing.Plus,
+	lexing.MinusMinus:   lexing.Minus,
+}
+
+func (i *Interpreter) evaluateCompoundAssignExpr(expr ast.CompoundAssignExpr) interface{} {
+	_, value := i.update(expr.Variable, func(oldValue interface{}) interface{} {
+		return i.arithmetic(expr.Operator, compoundOperations[expr.Operator.TokenType],
+			oldValue, i.Evaluate(expr.Value))
+	})
 	return value
 }
 
+func (i *Interpreter) evaluateIncrementExpr(expr ast.IncrementExpr) interface{} {
+	oldValue, value := i.update(expr.Variable, func(oldValue interface{}) interface{} {
+		requireNumberOperand(expr.Operator, oldValue)
+		return i.arithmetic(expr.Operator, compoundOperations[expr.Operator.TokenType], oldValue, 1.0)
+	})
+
+	if expr.Prefix {
+		return value
+	}
+	return oldValue
+}
+
+// update replaces the value of an assignment target with the one computed
+// from its old value. The array and index of an index target are evaluated
+// once, before the computation.
+func (i *Interpreter) update(target ast.Expr,
+	compute func(oldValue interface{}) interface{}) (oldValue interface{}, value interface{}) {
+	switch target.(type) {
+	case ast.VariableExpr:
+		name := target.(ast.VariableExpr).Name
+		oldValue = i.env.get(name)
+		value = compute(oldValue)
+		i.env.assign(name, value)
+	case ast.IndexExpr:
+		expr := target.(ast.IndexExpr)
+		object := i.Evaluate(expr.Array)
+		indexValue := i.Evaluate(expr.IndexExpr)
+		oldValue = getIndex(expr.Bracket, object, indexValue)
+		value = compute(oldValue)
+		i.setIndex(expr.Bracket, object, indexValue, value)
+	}
+	return oldValue, value
+}
+
 func (i *Interpreter) evaluateTernaryExpr(expr ast.TernaryExpr) interface{} {
 	conditionValue := i.Evaluate(expr.Condition)
+	if i.branchHook != nil {
+		i.branchHook.Branch(expr, isTruthy(conditionValue))
+	}
 
 	var value interface{}
 	if isTruthy(conditionValue) {
@@ -204,8 +317,14 @@ func (i *Interpreter) evaluateTernaryExpr(expr ast.TernaryExpr) interface{} {
 func (i *Interpreter) evaluateLogicalExpr(expr ast.LogicalExpr) interface{} {
 	leftValue := i.Evaluate(expr.LeftExpr)
 
-	if expr.Operator.TokenType == lexing.Or && isTruthy(leftValue) ||
-		expr.Operator.TokenType == lexing.And && !isTruthy(leftValue) {
+	shortCircuit := expr.Operator.TokenType == lexing.Or && isTruthy(leftValue) ||
+		expr.Operator.TokenType == lexing.And && !isTruthy(leftValue) ||
+		expr.Operator.TokenType == lexing.QuestionQuestion && leftValue != nil
+	if i.branchHook != nil {
+		i.branchHook.Branch(expr, !shortCircuit)
+	}
+
+	if shortCircuit {
 		return leftValue
 	}
 
@@ -213,22 +332,56 @@ func (i *Interpreter) evaluateLogicalExpr(expr ast.LogicalExpr) interface{} {
 }
 
 func (i *Interpreter) evaluateCallExpr(expr ast.CallExpr) interface{} {
-	calleeValue := i.Evaluate(expr.Callee)
+	value, _ := i.evaluateChain(expr)
+	return value
+}
+
+// evaluateChain evaluates a call, index or property access with the links
+// of the postfix chain before it. skipped reports that an optional link
+// found nil, which makes the rest of the chain nil without evaluating it.
+func (i *Interpreter) evaluateChain(expr ast.Expr) (value interface{}, skipped bool) {
+	var receiver ast.Expr
+	var optional bool
+	switch expr.(type) {
+	case ast.CallExpr:
+		receiver, optional = expr.(ast.CallExpr).Callee, expr.(ast.CallExpr).Optional
+	case ast.IndexExpr:
+		receiver, optional = expr.(ast.IndexExpr).Array, expr.(ast.IndexExpr).Optional
+	case ast.GetExpr:
+		receiver, optional = expr.(ast.GetExpr).Object, expr.(ast.GetExpr).Optional
+	default:
+		return i.Evaluate(expr), false
+	}
 
-	argumentValues := make([]interface{}, 0)
-	for _, argumentExpr := range expr.Arguments {
-		argumentValues = append(argumentValues, i.Evaluate(argumentExpr))
+	object, skipped := i.evaluateChain(receiver)
+	if skipped || optional && object == nil {
+		return nil, true
 	}
 
+	switch expr.(type) {
+	case ast.CallExpr:
+		return i.callValue(expr.(ast.CallExpr), object), false
+	case ast.IndexExpr:
+		indexExpr := expr.(ast.IndexExpr)
+		return getIndex(indexExpr.Bracket, object, i.Evaluate(indexExpr.IndexExpr)), false
+	}
+	return getProperty(expr.(ast.GetExpr), object), false
+}
+
+func (i *Interpreter) callValue(expr ast.CallExpr, calleeValue interface{}) interface{} {
+	argumentValues, named := i.evaluateArguments(expr.Arguments)
+
 	switch calleeValue.(type) {
 	case Caller:
 		function := calleeValue.(Caller)
-		if function.ParametersCount() >= 0 && function.ParametersCount() != len(argumentValues) {
-			runtimeError(expr.Paren,
-				fmt.Sprintf("expect %d arguments, got %d",
-					function.ParametersCount(), len(argumentValues)))
+		if len(named) > 0 {
+			argumentValues = bindNamed(expr.Paren, function, argumentValues, named)
 		}
-		return function.Call(i, argumentValues)
+		checkArity(expr.Paren, function, len(argumentValues))
+
+		i.enterCall(expr.Paren)
+		defer i.exitCall(i.callToken)
+		return i.invoke(function, callToken(expr), argumentValues)
 	}
 
 	runtimeError(expr.Paren, "invalid object to call")
@@ -236,41 +389,139 @@ func (i *Interpreter) evaluateCallExpr(expr ast.CallExpr) interface{} {
 }
 
 func (i *Interpreter) evaluateIndexExpr(expr ast.IndexExpr) interface{} {
-	arrayValue := i.Evaluate(expr.Array)
+	value, _ := i.evaluateChain(expr)
+	return value
+}
 
-	switch arrayValue.(type) {
-	case []interface{}:
-		array := arrayValue.([]interface{})
+func getIndex(bracket lexing.Token, object interface{}, indexValue interface{}) interface{} {
+	switch object.(type) {
+	case *Array:
+		array := object.(*Array).Elements
+		return array[arrayIndex(bracket, array, indexValue)]
+	case map[string]interface{}:
+		// A missing key reads as nil.
+		return object.(map[string]interface{})[mapKey(bracket, indexValue)]
+	}
 
-		indexValue := i.Evaluate(expr.IndexExpr)
-		var index int
-		if isNumber(indexValue) {
-			index = int(indexValue.(float64))
-		} else {
-			runtimeError(expr.Bracket, "index must be integer number")
-		}
+	runtimeError(bracket, "only arrays and maps can be indexed")
+	return nil
+}
 
-		if index >= len(array) {
-			runtimeError(expr.Bracket,
-				fmt.Sprintf("index %d out of range in array with len %d", index, len(array)))
+func (i *Interpreter) setIndex(bracket lexing.Token, object interface{}, indexValue interface{}, value interface{}) {
+	switch object.(type) {
+	case *Array:
+		array := object.(*Array)
+		index := arrayIndex(bracket, array.Elements, indexValue)
+		if array.frozen {
+			runtimeError(bracket, "cannot assign to index of frozen array")
 		}
+		array.Elements[index] = value
+		return
+	case map[string]interface{}:
+		object.(map[string]interface{})[mapKey(bracket, indexValue)] = value
+		return
+	}
+
+	runtimeError(bracket, "only arrays and maps can be indexed")
+}
 
-		return array[index]
+func arrayIndex(bracket lexing.Token, array []interface{}, indexValue interface{}) int {
+	var index int
+	if isNumber(indexValue) {
+		index = int(indexValue.(float64))
+	} else {
+		runtimeError(bracket, "index must be integer number")
 	}
 
-	runtimeError(expr.Bracket, "invalid array object")
-	return nil
+	if index < 0 || index >= len(array) {
+		runtimeError(bracket,
+			fmt.Sprintf("index %d out of range in array with len %d", index, len(array)))
+	}
+	return index
+}
+
+func mapKey(bracket lexing.Token, keyValue interface{}) string {
+	key, ok := keyValue.(string)
+	if !ok {
+		runtimeError(bracket, "map key must be string")
+	}
+	return key
 }
 
 func (i *Interpreter) evaluateArrayExpr(expr ast.ArrayExpr) interface{} {
-	array := make([]interface{}, len(expr.Elements))
+	elements := i.evaluateElements(expr.Elements)
+	i.allocate(expr.Span().Start.Line, len(elements)*valueSize)
+	return NewArray(elements)
+}
+
+// evaluateElements evaluates array literal elements or call arguments,
+// inserting the elements of spread arrays in their place.
+func (i *Interpreter) evaluateElements(exprs []ast.Expr) []interface{} {
+	values := make([]interface{}, 0, len(exprs))
+	for _, expr := range exprs {
+		values = i.appendElement(values, expr)
+	}
+	return values
+}
+
+// evaluateArguments evaluates call arguments in order, the named ones are
+// returned apart.
+func (i *Interpreter) evaluateArguments(exprs []ast.Expr) ([]interface{}, []namedValue) {
+	values := make([]interface{}, 0, len(exprs))
+	var named []namedValue
+	for _, expr := range exprs {
+		if argument, ok := expr.(ast.NamedArgument); ok {
+			named = append(named, namedValue{name: argument.Name, value: i.Evaluate(argument.Value)})
+			continue
+		}
+		values = i.appendElement(values, expr)
+	}
+	return values, named
+}
 
-	for index, elemExpr := range expr.Elements {
-		elemValue := i.Evaluate(elemExpr)
-		array[index] = elemValue
+func (i *Interpreter) appendElement(values []interface{}, expr ast.Expr) []interface{} {
+	spread, ok := expr.(ast.SpreadExpr)
+	if !ok {
+		return append(values, i.Evaluate(expr))
 	}
 
-	return array
+	value := i.Evaluate(spread.Expr)
+	array, ok := value.(*Array)
+	if !ok {
+		runtimeError(spread.Ellipsis, "spread expect array, got "+Repr(value))
+	}
+	return append(values, array.Elements...)
+}
+
+func (i *Interpreter) evaluateMapExpr(expr ast.MapExpr) interface{} {
+	i.allocate(expr.Brace.Line, len(expr.Keys)*2*valueSize)
+	entries := make(map[string]interface{}, len(expr.Keys))
+
+	for index, keyExpr := range expr.Keys {
+		key := mapKey(expr.Brace, i.Evaluate(keyExpr))
+		entries[key] = i.Evaluate(expr.Values[index])
+	}
+
+	return entries
+}
+
+func (i *Interpreter) evaluateGetExpr(expr ast.GetExpr) interface{} {
+	value, _ := i.evaluateChain(expr)
+	return value
+}
+
+func getProperty(expr ast.GetExpr, objectValue interface{}) interface{} {
+	switch objectValue.(type) {
+	case Object:
+		value, ok := objectValue.(Object).Get(expr.Name.Lexeme)
+		if !ok {
+			runtimeError(expr.Name, fmt.Sprintf("undefined property '%s'", expr.Name.Lexeme))
+		}
+		return value
+	}
+
+	runtimeError(expr.Name, "only modules and objects have properties")
+	return nil
 }
 
 func (i *Interpreter) evaluateLambdaExpr(expr ast.LambdaExpr) interface{} {
@@ -280,6 +531,103 @@ func (i *Interpreter) evaluateLambdaExpr(expr ast.LambdaExpr) interface{} {
 	}
 }
 
+// evaluateMatchExpr evaluates the arm selected by the subject. The value
+// is the one of the arm expression, a block arm or no matching arm gives
+// nil.
+func (i *Interpreter) evaluateMatchExpr(expr ast.MatchExpr) interface{} {
+	enclosingEnv := i.env
+	defer func() {
+		i.env = enclosingEnv
+	}()
+
+	arm, ok := i.selectArm(i.Evaluate(expr.Subject), expr.Arms)
+	if !ok {
+		return nil
+	}
+
+	switch arm.Body.(type) {
+	case ast.BlockStmt:
+		i.Execute(arm.Body.(ast.BlockStmt))
+		return nil
+	}
+	return i.Evaluate(arm.Body.(ast.Expr))
+}
+
+// selectArm returns the first arm whose pattern matches the subject and
+// whose guard holds. The names bound by its pattern are left defined in
+// the current environment.
+func (i *Interpreter) selectArm(subject interface{}, arms []ast.MatchArm) (ast.MatchArm, bool) {
+	enclosingEnv := i.env
+	for _, arm := range arms {
+		i.env = NewEnvironment(enclosingEnv)
+		if i.matchArm(arm, subject) && (arm.Guard == nil || isTruthy(i.Evaluate(arm.Guard))) {
+			return arm, true
+		}
+	}
+	i.env = enclosingEnv
+	return ast.MatchArm{}, false
+}
+
+func (i *Interpreter) matchArm(arm ast.MatchArm, subject interface{}) bool {
+	if arm.Keyword.TokenType == lexing.Else {
+		return true
+	}
+	for _, pattern := range arm.Patterns {
+		if i.matchPattern(pattern, subject) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPattern reports whether the value matches the pattern, defining
+// the names the pattern binds in the current environment.
+func (i *Interpreter) matchPattern(pattern ast.Expr, value interface{}) bool {
+	switch pattern.(type) {
+	case ast.LiteralExpr:
+		return Equal(pattern.(ast.LiteralExpr).LiteralValue, value)
+	case ast.VariableExpr:
+		i.env.define(pattern.(ast.VariableExpr).Name.Lexeme, value)
+		return true
+	case ast.ArrayPattern:
+		arrayPattern := pattern.(ast.ArrayPattern)
+		header, ok := value.(*Array)
+		if !ok {
+			return false
+		}
+		array := header.Elements
+		switch {
+		case arrayPattern.Rest == nil && len(array) != len(arrayPattern.Elements):
+			return false
+		case len(array) < len(arrayPattern.Elements):
+			return false
+		}
+
+		for index, element := range arrayPattern.Elements {
+			if !i.matchPattern(element, array[index]) {
+				return false
+			}
+		}
+		if arrayPattern.Rest != nil {
+			rest := array[len(arrayPattern.Elements):]
+			i.allocate(arrayPattern.Bracket.Line, len(rest)*valueSize)
+			return i.matchPattern(arrayPattern.Rest, NewArray(append([]interface{}{}, rest...)))
+		}
+		return true
+	}
+	return false
+}
+
+// callToken returns the token a call is reported at: the callee name
+// when calling a named function, the closing paren otherwise.
+func callToken(expr ast.CallExpr) lexing.Token {
+	switch expr.Callee.(type) {
+	case ast.VariableExpr:
+		return expr.Callee.(ast.VariableExpr).Name
+	}
+	return expr.Paren
+}
+
 func requireNumberOperand(operator lexing.Token, operand interface{}) {
 	switch operand.(type) {
 	case float64:
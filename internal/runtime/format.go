@@ -0,0 +1,170 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Str converts a value to the text the print statement shows, it is the
+// same as Repr except that a string is shown as is.
+func Str(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	return Repr(value)
+}
+
+// Repr renders a value the way it is written in source, strings are
+// quoted. Arrays and maps containing themselves are shown as [...] and
+// {...}.
+func Repr(value interface{}) string {
+	var builder strings.Builder
+	writeValue(&builder, value, make(map[uintptr]bool))
+	return builder.String()
+}
+
+func writeValue(builder *strings.Builder, value interface{}, visiting map[uintptr]bool) {
+	switch value.(type) {
+	case nil:
+		builder.WriteString("nil")
+	case bool:
+		builder.WriteString(strconv.FormatBool(value.(bool)))
+	case float64:
+		builder.WriteString(formatNumber(value.(float64)))
+	case string:
+		builder.WriteString(strconv.Quote(value.(string)))
+	case Function:
+		function := value.(Function)
+		fmt.Fprintf(builder, "<fn %s/%s>", function.Declaration.Name.Lexeme, formatArity(function.Arity()))
+	case LambdaFunction:
+		fmt.Fprintf(builder, "<fn lambda/%s>", formatArity(value.(LambdaFunction).Arity()))
+	case *Module:
+		fmt.Fprintf(builder, "<module %s>", value.(*Module).Name)
+	case *File:
+		fmt.Fprintf(builder, "<file %s>", value.(*File).Path)
+	case Caller:
+		fmt.Fprintf(builder, "<native %s>", nativeName(value.(Caller)))
+	case *Array:
+		array := value.(*Array)
+		pointer := reflect.ValueOf(array).Pointer()
+		if visiting[pointer] {
+			builder.WriteString("[...]")
+			return
+		}
+		visiting[pointer] = true
+		defer delete(visiting, pointer)
+
+		builder.WriteString("[")
+		for i, element := range array.Elements {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			writeValue(builder, element, visiting)
+		}
+		builder.WriteString("]")
+	case map[string]interface{}:
+		entries := value.(map[string]interface{})
+		pointer := reflect.ValueOf(entries).Pointer()
+		if visiting[pointer] {
+			builder.WriteString("{...}")
+			return
+		}
+		visiting[pointer] = true
+		defer delete(visiting, pointer)
+
+		builder.WriteString("{")
+		for i, key := range sortedKeys(entries) {
+			if i > 0 {
+				builder.WriteString(", ")
+			}
+			builder.WriteString(strconv.Quote(key) + ": ")
+			writeValue(builder, entries[key], visiting)
+		}
+		builder.WriteString("}")
+	default:
+		fmt.Fprintf(builder, "%v", value)
+	}
+}
+
+// formatNumber shows integers without a fraction and switches to an
+// exponent only for very large or very small numbers.
+func formatNumber(number float64) string {
+	switch {
+	case math.IsNaN(number):
+		return "nan"
+	case math.IsInf(number, 1):
+		return "inf"
+	case math.IsInf(number, -1):
+		return "-inf"
+	case number != 0 && (math.Abs(number) >= 1e21 || math.Abs(number) < 1e-7):
+		return strconv.FormatFloat(number, 'g', -1, 64)
+	}
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
+
+// nativeName finds the global or module member name a native is defined
+// with, natives defined elsewhere are named after their type.
+func nativeName(native Caller) string {
+	if disabled, ok := native.(DisabledFunc); ok {
+		return disabled.Name
+	}
+
+	nativeType := reflect.TypeOf(native)
+	for name, global := range natives {
+		if reflect.TypeOf(global) == nativeType {
+			return name
+		}
+	}
+	for _, module := range modules {
+		for name, member := range module.Members {
+			if reflect.TypeOf(member) == nativeType {
+				return module.Name + "." + name
+			}
+		}
+	}
+
+	name := strings.TrimSuffix(nativeType.Name(), "Func")
+	if name == "" {
+		return "fn"
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func sortedKeys(entries map[string]interface{}) []string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+type StrFunc struct {
+}
+
+func (f StrFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	text := Str(arguments[0])
+	interpreter.allocate(interpreter.callToken.Line, len(text))
+	return text
+}
+
+func (f StrFunc) ParametersCount() int {
+	return 1
+}
+
+type ReprFunc struct {
+}
+
+func (f ReprFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	text := Repr(arguments[0])
+	interpreter.allocate(interpreter.callToken.Line, len(text))
+	return text
+}
+
+func (f ReprFunc) ParametersCount() int {
+	return 1
+}
@@ -0,0 +1,423 @@
+package runtime
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// legacyVerbs maps the verbs of Go's fmt package, which printf keeps
+// accepting for existing scripts, to the types of replacement fields.
+var legacyVerbs = map[byte]rune{
+	'v': 0, 't': 0, 's': 's', 'q': 'r',
+	'd': 'd', 'x': 'x', 'X': 'X', 'o': 'o', 'b': 'b',
+	'f': 'f', 'e': 'e', 'g': 'g',
+}
+
+// isLegacyFormat reports whether a printf format is written with verbs
+// rather than replacement fields. A format without any verb, such as
+// "100%", is not, its percent signs are then written as they are.
+func isLegacyFormat(format string) bool {
+	if strings.Contains(format, "{") {
+		return false
+	}
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' {
+			continue
+		}
+		if strings.HasPrefix(format[i:], "%%") {
+			return true
+		}
+		if _, length, err := parseLegacyVerb(format[i+1:]); length > 0 && err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// legacyFormatString replaces the verbs of a legacy format with the
+// arguments, each verb is translated to a replacement field so that Lox
+// values are shown the way format shows them.
+func legacyFormatString(format string, arguments []interface{}) (string, error) {
+	f := formatter{arguments: arguments}
+	var out strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		switch {
+		case strings.HasPrefix(format[i:], "%%"):
+			out.WriteByte('%')
+			i++
+		case format[i] == '%':
+			spec, length, err := parseLegacyVerb(format[i+1:])
+			if err != nil {
+				return "", err
+			}
+			if length == 0 {
+				return "", fmt.Errorf("'%%' at column %d is not followed by a verb, write '%%%%' for a percent sign", i+1)
+			}
+			verb := format[i : i+1+length]
+
+			value, err := f.argument("")
+			if err != nil {
+				return "", fmt.Errorf("verb %s: %v", verb, err)
+			}
+			text, err := spec.apply(value)
+			if err != nil {
+				return "", fmt.Errorf("verb %s: %v", verb, err)
+			}
+			out.WriteString(text)
+			i += length
+		default:
+			out.WriteByte(format[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+// parseLegacyVerb parses the flags, width, precision and verb following a
+// '%': ("-" | "+" | "0")* INTEGER? ("." INTEGER?)? VERB. The length is zero
+// if the text does not start with a verb.
+func parseLegacyVerb(text string) (formatSpec, int, error) {
+	spec := formatSpec{fill: ' ', precision: -1}
+	i := 0
+
+	for ; i < len(text) && strings.IndexByte("-+0", text[i]) >= 0; i++ {
+		switch text[i] {
+		case '-':
+			spec.align = '<'
+		case '+':
+			spec.sign = '+'
+		case '0':
+			spec.zero = true
+		}
+	}
+
+	start := i
+	for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+		i++
+	}
+	if start < i {
+		width, err := formatBound("width", text[start:i])
+		if err != nil {
+			return spec, 0, err
+		}
+		spec.width = width
+	}
+
+	if i < len(text) && text[i] == '.' {
+		i++
+		start = i
+		for i < len(text) && text[i] >= '0' && text[i] <= '9' {
+			i++
+		}
+		spec.precision = 0
+		if start < i {
+			precision, err := formatBound("precision", text[start:i])
+			if err != nil {
+				return spec, 0, err
+			}
+			spec.precision = precision
+		}
+	}
+
+	if i == len(text) {
+		return spec, 0, nil
+	}
+	verb, ok := legacyVerbs[text[i]]
+	if !ok {
+		return spec, 0, nil
+	}
+	spec.verb = verb
+	return spec, i + 1, nil
+}
+
+// maxFormatWidth bounds the width and the precision of a replacement
+// field, the text is built before its size can be checked.
+const maxFormatWidth = 1000
+
+// formatSpec is the part of a replacement field after the colon:
+// [[fill]align][sign][0][width][.precision][type].
+type formatSpec struct {
+	fill      rune
+	align     rune
+	sign      rune
+	zero      bool
+	width     int
+	precision int
+	verb      rune
+}
+
+// formatter replaces the fields of a format string with the arguments.
+// Fields are numbered automatically ({}), manually ({0}) or named after an
+// entry of the map passed as the last argument ({name}).
+type formatter struct {
+	arguments []interface{}
+	next      int
+	automatic bool
+	manual    bool
+}
+
+func formatString(format string, arguments []interface{}) (string, error) {
+	f := formatter{arguments: arguments}
+	var out strings.Builder
+
+	for i := 0; i < len(format); i++ {
+		switch {
+		case strings.HasPrefix(format[i:], "{{"):
+			out.WriteByte('{')
+			i++
+		case strings.HasPrefix(format[i:], "}}"):
+			out.WriteByte('}')
+			i++
+		case format[i] == '}':
+			return "", fmt.Errorf("single '}' at column %d, write '}}' for a brace", i+1)
+		case format[i] == '{':
+			end := strings.IndexByte(format[i:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("unclosed '{' at column %d", i+1)
+			}
+			text, err := f.field(format[i+1 : i+end])
+			if err != nil {
+				return "", fmt.Errorf("field {%s}: %v", format[i+1:i+end], err)
+			}
+			out.WriteString(text)
+			i += end
+		default:
+			out.WriteByte(format[i])
+		}
+	}
+
+	return out.String(), nil
+}
+
+func (f *formatter) field(field string) (string, error) {
+	name, specText := field, ""
+	if colon := strings.IndexByte(field, ':'); colon >= 0 {
+		name, specText = field[:colon], field[colon+1:]
+	}
+
+	value, err := f.argument(name)
+	if err != nil {
+		return "", err
+	}
+	spec, err := parseFormatSpec(specText)
+	if err != nil {
+		return "", err
+	}
+	return spec.apply(value)
+}
+
+func (f *formatter) argument(name string) (interface{}, error) {
+	index, err := strconv.Atoi(name)
+	switch {
+	case name == "":
+		if f.manual {
+			return nil, fmt.Errorf("cannot mix automatic and manual field numbering")
+		}
+		f.automatic = true
+		index = f.next
+		f.next++
+	case err == nil:
+		if f.automatic {
+			return nil, fmt.Errorf("cannot mix automatic and manual field numbering")
+		}
+		f.manual = true
+	default:
+		if len(f.arguments) == 0 {
+			return nil, fmt.Errorf("named field expects a map as the last argument")
+		}
+		entries, ok := f.arguments[len(f.arguments)-1].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("named field expects a map as the last argument")
+		}
+		value, ok := entries[name]
+		if !ok {
+			return nil, fmt.Errorf("no entry '%s' in the map argument", name)
+		}
+		return value, nil
+	}
+
+	if index < 0 || index >= len(f.arguments) {
+		return nil, fmt.Errorf("no argument %d, %d given", index, len(f.arguments))
+	}
+	return f.arguments[index], nil
+}
+
+func parseFormatSpec(text string) (formatSpec, error) {
+	spec := formatSpec{fill: ' ', precision: -1}
+	runes := []rune(text)
+	i := 0
+
+	isAlign := func(r rune) bool { return r == '<' || r == '>' || r == '^' }
+	switch {
+	case len(runes) >= 2 && isAlign(runes[1]):
+		spec.fill, spec.align = runes[0], runes[1]
+		i = 2
+	case len(runes) >= 1 && isAlign(runes[0]):
+		spec.align = runes[0]
+		i = 1
+	}
+
+	if i < len(runes) && (runes[i] == '+' || runes[i] == '-' || runes[i] == ' ') {
+		spec.sign = runes[i]
+		i++
+	}
+	if i < len(runes) && runes[i] == '0' {
+		spec.zero = true
+		i++
+	}
+
+	start := i
+	for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+		i++
+	}
+	if start < i {
+		width, err := formatBound("width", string(runes[start:i]))
+		if err != nil {
+			return spec, err
+		}
+		spec.width = width
+	}
+
+	if i < len(runes) && runes[i] == '.' {
+		i++
+		start = i
+		for i < len(runes) && runes[i] >= '0' && runes[i] <= '9' {
+			i++
+		}
+		if start == i {
+			return spec, fmt.Errorf("missing precision after '.'")
+		}
+		precision, err := formatBound("precision", string(runes[start:i]))
+		if err != nil {
+			return spec, err
+		}
+		spec.precision = precision
+	}
+
+	if i < len(runes) {
+		if !strings.ContainsRune("srdfegxXob%", runes[i]) {
+			return spec, fmt.Errorf("unknown format type '%c'", runes[i])
+		}
+		spec.verb = runes[i]
+		i++
+	}
+	if i < len(runes) {
+		return spec, fmt.Errorf("invalid format spec '%s'", text)
+	}
+
+	return spec, nil
+}
+
+func formatBound(name string, digits string) (int, error) {
+	bound, err := strconv.Atoi(digits)
+	if err != nil || bound > maxFormatWidth {
+		return 0, fmt.Errorf("%s %s is larger than %d", name, digits, maxFormatWidth)
+	}
+	return bound, nil
+}
+
+func (s formatSpec) apply(value interface{}) (string, error) {
+	number, isNumber := value.(float64)
+
+	var text string
+	switch s.verb {
+	case 0, 's', 'r':
+		if s.verb == 0 && isNumber {
+			return s.pad(s.number(number, 'f')), nil
+		}
+		if s.verb == 'r' {
+			text = Repr(value)
+		} else {
+			text = Str(value)
+		}
+		if s.precision >= 0 && utf8.RuneCountInString(text) > s.precision {
+			text = string([]rune(text)[:s.precision])
+		}
+		return s.pad(text, false), nil
+	}
+
+	if !isNumber {
+		return "", fmt.Errorf("'%c' expects a number, got %s", s.verb, Repr(value))
+	}
+	if strings.ContainsRune("dxXob", s.verb) && number != math.Trunc(number) {
+		return "", fmt.Errorf("'%c' expects an integer, got %s", s.verb, Repr(value))
+	}
+	return s.pad(s.number(number, s.verb)), nil
+}
+
+// number formats the number without its sign, which is returned apart so
+// that zero padding goes between them.
+func (s formatSpec) number(number float64, verb rune) (string, bool) {
+	sign := ""
+	switch {
+	case number < 0:
+		sign = "-"
+	case s.sign == '+':
+		sign = "+"
+	case s.sign == ' ':
+		sign = " "
+	}
+	number = math.Abs(number)
+
+	var digits string
+	switch {
+	case math.IsNaN(number) || math.IsInf(number, 0):
+		digits = formatNumber(number)
+	case verb == 'd':
+		digits = strconv.FormatFloat(number, 'f', 0, 64)
+	case verb == 'x' || verb == 'X' || verb == 'o' || verb == 'b':
+		base := map[rune]int{'x': 16, 'X': 16, 'o': 8, 'b': 2}[verb]
+		digits = strconv.FormatUint(uint64(number), base)
+		if verb == 'X' {
+			digits = strings.ToUpper(digits)
+		}
+	case verb == '%':
+		digits = strconv.FormatFloat(number*100, 'f', s.defaultPrecision(6), 64) + "%"
+	case verb == 'f' && s.precision < 0 && s.verb == 0:
+		digits = formatNumber(number)
+	case verb == 'f' || verb == 'e':
+		digits = strconv.FormatFloat(number, byte(verb), s.defaultPrecision(6), 64)
+	case verb == 'g':
+		digits = strconv.FormatFloat(number, 'g', s.defaultPrecision(-1), 64)
+	}
+
+	if zeros := s.width - utf8.RuneCountInString(sign+digits); s.zero && s.align == 0 && zeros > 0 {
+		digits = strings.Repeat("0", zeros) + digits
+	}
+	return sign + digits, true
+}
+
+func (s formatSpec) defaultPrecision(precision int) int {
+	if s.precision >= 0 {
+		return s.precision
+	}
+	return precision
+}
+
+// pad fills the text up to the width, numbers are aligned to the right and
+// other values to the left unless the spec says otherwise.
+func (s formatSpec) pad(text string, isNumber bool) string {
+	padding := s.width - utf8.RuneCountInString(text)
+	if padding <= 0 {
+		return text
+	}
+
+	align := s.align
+	if align == 0 && isNumber {
+		align = '>'
+	}
+	fill := strings.Repeat(string(s.fill), padding)
+
+	switch align {
+	case '>':
+		return fill + text
+	case '^':
+		half := padding / 2 * utf8.RuneLen(s.fill)
+		return fill[:half] + text + fill[half:]
+	}
+	return text + fill
+}
@@ -0,0 +1,366 @@
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+)
+
+var fsModule = &Module{
+	Name: "fs",
+	Members: map[string]interface{}{
+		"readFile":   ReadFileFunc{},
+		"writeFile":  WriteFileFunc{},
+		"appendFile": AppendFileFunc{},
+		"readLines":  ReadLinesFunc{},
+		"exists":     ExistsFunc{},
+		"listDir":    ListDirFunc{},
+		"mkdir":      MkdirFunc{},
+		"remove":     RemoveFunc{},
+		"open":       OpenFunc{},
+	},
+}
+
+// fileError raises the error of a failed file operation, the path is left
+// out of the error of the os package as it is shown separately.
+func fileError(interpreter *Interpreter, operation string, path string, err error) {
+	var pathError *fs.PathError
+	if errors.As(err, &pathError) {
+		err = pathError.Err
+	}
+	runtimeError(interpreter.callToken, fmt.Sprintf("%s '%s': %v", operation, path, err))
+}
+
+// stringArguments checks that all arguments are strings.
+func stringArguments(interpreter *Interpreter, name string, arguments []interface{}) []string {
+	strs := make([]string, len(arguments))
+	for index, argument := range arguments {
+		str, ok := argument.(string)
+		if !ok {
+			runtimeError(interpreter.callToken, fmt.Sprintf("%s expect string arguments", name))
+		}
+		strs[index] = str
+	}
+	return strs
+}
+
+type ReadFileFunc struct {
+}
+
+func (f ReadFileFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "readFile", arguments)[0]
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fileError(interpreter, "readFile", path, err)
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(content))
+	return string(content)
+}
+
+func (f ReadFileFunc) ParametersCount() int {
+	return 1
+}
+
+type WriteFileFunc struct {
+}
+
+func (f WriteFileFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	args := stringArguments(interpreter, "writeFile", arguments)
+	if err := os.WriteFile(args[0], []byte(args[1]), 0644); err != nil {
+		fileError(interpreter, "writeFile", args[0], err)
+	}
+	return nil
+}
+
+func (f WriteFileFunc) ParametersCount() int {
+	return 2
+}
+
+type AppendFileFunc struct {
+}
+
+func (f AppendFileFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	args := stringArguments(interpreter, "appendFile", arguments)
+	file, err := os.OpenFile(args[0], os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		fileError(interpreter, "appendFile", args[0], err)
+	}
+	defer file.Close()
+
+	if _, err := file.WriteString(args[1]); err != nil {
+		fileError(interpreter, "appendFile", args[0], err)
+	}
+	return nil
+}
+
+func (f AppendFileFunc) ParametersCount() int {
+	return 2
+}
+
+type ReadLinesFunc struct {
+}
+
+func (f ReadLinesFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "readLines", arguments)[0]
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fileError(interpreter, "readLines", path, err)
+	}
+
+	text := strings.TrimSuffix(string(content), "\n")
+	lines := make([]interface{}, 0)
+	if text != "" {
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, strings.TrimSuffix(line, "\r"))
+		}
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(content)+len(lines)*valueSize)
+	return NewArray(lines)
+}
+
+func (f ReadLinesFunc) ParametersCount() int {
+	return 1
+}
+
+type ExistsFunc struct {
+}
+
+func (f ExistsFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "exists", arguments)[0]
+	_, err := os.Stat(path)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, fs.ErrNotExist):
+		return false
+	}
+
+	fileError(interpreter, "exists", path, err)
+	return nil
+}
+
+func (f ExistsFunc) ParametersCount() int {
+	return 1
+}
+
+type ListDirFunc struct {
+}
+
+// Call returns the names of the entries of the directory, sorted.
+func (f ListDirFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "listDir", arguments)[0]
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		fileError(interpreter, "listDir", path, err)
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(entries)*valueSize)
+	names := make([]interface{}, len(entries))
+	for index, entry := range entries {
+		names[index] = entry.Name()
+	}
+	return NewArray(names)
+}
+
+func (f ListDirFunc) ParametersCount() int {
+	return 1
+}
+
+type MkdirFunc struct {
+}
+
+// Call creates the directory along with any missing parent.
+func (f MkdirFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "mkdir", arguments)[0]
+	if err := os.MkdirAll(path, 0755); err != nil {
+		fileError(interpreter, "mkdir", path, err)
+	}
+	return nil
+}
+
+func (f MkdirFunc) ParametersCount() int {
+	return 1
+}
+
+type RemoveFunc struct {
+}
+
+// Call removes a file or an empty directory.
+func (f RemoveFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	path := stringArguments(interpreter, "remove", arguments)[0]
+	if err := os.Remove(path); err != nil {
+		fileError(interpreter, "remove", path, err)
+	}
+	return nil
+}
+
+func (f RemoveFunc) ParametersCount() int {
+	return 1
+}
+
+type OpenFunc struct {
+}
+
+// Call opens a file for reading with mode "r", writing with "w" or
+// appending with "a".
+func (f OpenFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	args := stringArguments(interpreter, "open", arguments)
+	path, mode := args[0], args[1]
+
+	var flag int
+	switch mode {
+	case "r":
+		flag = os.O_RDONLY
+	case "w":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	case "a":
+		flag = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	default:
+		runtimeError(interpreter.callToken, fmt.Sprintf("invalid file mode '%s', expect \"r\", \"w\" or \"a\"", mode))
+	}
+
+	file, err := os.OpenFile(path, flag, 0644)
+	if err != nil {
+		fileError(interpreter, "open", path, err)
+	}
+
+	handle := &File{Path: path, file: file, reader: bufio.NewReader(file)}
+	interpreter.files[handle] = true
+	return handle
+}
+
+func (f OpenFunc) ParametersCount() int {
+	return 2
+}
+
+// File is a file handle returned by fs.open.
+type File struct {
+	Path string
+
+	file   *os.File
+	reader *bufio.Reader
+	closed bool
+}
+
+func (f *File) Get(name string) (interface{}, bool) {
+	switch name {
+	case "read":
+		return FileReadFunc{File: f}, true
+	case "readLine":
+		return FileReadLineFunc{File: f}, true
+	case "write":
+		return FileWriteFunc{File: f}, true
+	case "close":
+		return FileCloseFunc{File: f}, true
+	case "path":
+		return f.Path, true
+	}
+	return nil, false
+}
+
+// close closes the file unless it is closed already.
+func (f *File) close() error {
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.file.Close()
+}
+
+func (f *File) requireOpen(interpreter *Interpreter, operation string) {
+	if f.closed {
+		runtimeError(interpreter.callToken, fmt.Sprintf("%s '%s': file is closed", operation, f.Path))
+	}
+}
+
+type FileReadFunc struct {
+	File *File
+}
+
+// Call reads the rest of the file.
+func (f FileReadFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	f.File.requireOpen(interpreter, "read")
+	content, err := io.ReadAll(f.File.reader)
+	if err != nil {
+		fileError(interpreter, "read", f.File.Path, err)
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(content))
+	return string(content)
+}
+
+func (f FileReadFunc) ParametersCount() int {
+	return 0
+}
+
+type FileReadLineFunc struct {
+	File *File
+}
+
+// Call reads the next line without its line ending, it returns nil at the
+// end of the file.
+func (f FileReadLineFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	f.File.requireOpen(interpreter, "readLine")
+	line, err := f.File.reader.ReadString('\n')
+	if err == io.EOF && line == "" {
+		return nil
+	}
+	if err != nil && err != io.EOF {
+		fileError(interpreter, "readLine", f.File.Path, err)
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(line))
+	return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+}
+
+func (f FileReadLineFunc) ParametersCount() int {
+	return 0
+}
+
+type FileWriteFunc struct {
+	File *File
+}
+
+func (f FileWriteFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	f.File.requireOpen(interpreter, "write")
+	text := stringArguments(interpreter, "write", arguments)[0]
+	if _, err := f.File.file.WriteString(text); err != nil {
+		fileError(interpreter, "write", f.File.Path, err)
+	}
+	return nil
+}
+
+func (f FileWriteFunc) ParametersCount() int {
+	return 1
+}
+
+type FileCloseFunc struct {
+	File *File
+}
+
+// Call closes the file, closing it again does nothing.
+func (f FileCloseFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	delete(interpreter.files, f.File)
+	if err := f.File.close(); err != nil {
+		fileError(interpreter, "close", f.File.Path, err)
+	}
+	return nil
+}
+
+// closeFiles closes the files the program left open when it ends.
+func (i *Interpreter) closeFiles() {
+	for file := range i.files {
+		file.close()
+		delete(i.files, file)
+	}
+}
+
+func (f FileCloseFunc) ParametersCount() int {
+	return 0
+}
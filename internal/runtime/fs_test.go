@@ -0,0 +1,55 @@
+package runtime
+
+import (
+	"context"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInterpretClosesFiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		failing bool
+	}{
+		{"left open", "var f = fs.open(args[0], \"w\");\nf.write(\"data\");\n", false},
+		{"runtime error", "var f = fs.open(args[0], \"w\");\nf.write(\"data\");\nnil();\n", true},
+		{"closed", "var f = fs.open(args[0], \"w\");\nf.write(\"data\");\nf.close();\n", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "out.txt")
+			lexer := lexing.NewLexer(test.source)
+			lexer.ScanTokens()
+			statements := parsing.NewParser(lexer.Tokens, lexer.Lines).Parse()
+
+			interpreter := NewInterpreter(WithArgs(path))
+			interpreter.SetOutput(ioutil.Discard)
+			if err := interpreter.Interpret(context.Background(), statements); (err != nil) != test.failing {
+				t.Fatalf("Interpret = %v", err)
+			}
+
+			value, _ := interpreter.Globals().Lookup("f")
+			file, ok := value.(*File)
+			if !ok {
+				t.Fatalf("f = %v, want a file", value)
+			}
+			if !file.closed || len(interpreter.files) != 0 {
+				t.Errorf("file left open after Interpret")
+			}
+			if _, err := file.file.WriteString("more"); err == nil {
+				t.Errorf("write to the file after Interpret succeeded")
+			}
+
+			content, err := os.ReadFile(path)
+			if err != nil || string(content) != "data" {
+				t.Errorf("file content = %q, %v, want %q", content, err, "data")
+			}
+		})
+	}
+}
@@ -0,0 +1,199 @@
+package runtime
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+)
+
+// Hook observes a running program. When a hook is attached the interpreter
+// calls it before executing every statement and keeps track of the Lox call
+// stack, without a hook neither is done.
+type Hook interface {
+	BeforeStmt(stmt ast.Stmt)
+}
+
+// CallHook is a Hook that is also notified when functions, natives
+// included, are entered and exited.
+type CallHook interface {
+	Hook
+	EnterCall(call Call)
+	ExitCall(call Call)
+}
+
+// BranchHook is a Hook that is also notified of the direction taken by
+// every conditional: if statements, ternaries and loop conditions report
+// whether the condition held, logical operators whether the right operand
+// was evaluated.
+type BranchHook interface {
+	Hook
+	Branch(node ast.Node, taken bool)
+}
+
+// Call describes a function call to a CallHook.
+type Call struct {
+	Function string
+	Native   bool
+	// Declared is the line the function was declared at, 0 for natives.
+	Declared int
+	// Line is the line of the call.
+	Line int
+}
+
+// Frame is a function call active at the moment a Hook is notified.
+type Frame struct {
+	Function string
+	Line     int
+	Env      *Environment
+}
+
+type frame struct {
+	function string
+	call     lexing.Token
+	env      *Environment
+}
+
+func (i *Interpreter) SetHook(hook Hook) {
+	i.hook = hook
+	i.callHook, _ = hook.(CallHook)
+	i.branchHook, _ = hook.(BranchHook)
+}
+
+// MultiHook returns a hook notifying every one of the hooks in turn.
+func MultiHook(hooks ...Hook) Hook {
+	return multiHook(hooks)
+}
+
+type multiHook []Hook
+
+func (m multiHook) BeforeStmt(stmt ast.Stmt) {
+	for _, hook := range m {
+		hook.BeforeStmt(stmt)
+	}
+}
+
+func (m multiHook) EnterCall(call Call) {
+	for _, hook := range m {
+		if callHook, ok := hook.(CallHook); ok {
+			callHook.EnterCall(call)
+		}
+	}
+}
+
+func (m multiHook) ExitCall(call Call) {
+	for _, hook := range m {
+		if callHook, ok := hook.(CallHook); ok {
+			callHook.ExitCall(call)
+		}
+	}
+}
+
+func (m multiHook) Branch(node ast.Node, taken bool) {
+	for _, hook := range m {
+		if branchHook, ok := hook.(BranchHook); ok {
+			branchHook.Branch(node, taken)
+		}
+	}
+}
+
+// CallStack returns the active calls, innermost first. The last frame is
+// the top level of the script.
+func (i *Interpreter) CallStack() []Frame {
+	stack := make([]Frame, 0, len(i.frames)+1)
+
+	line, env := 0, i.env
+	if i.stmt != nil {
+		line = i.stmt.Span().Start.Line
+	}
+
+	for index := len(i.frames) - 1; index >= 0; index-- {
+		stack = append(stack, Frame{
+			Function: i.frames[index].function,
+			Line:     line,
+			Env:      env,
+		})
+		line, env = i.frames[index].call.Line, i.frames[index].env
+	}
+
+	return append(stack, Frame{
+		Function: "<script>",
+		Line:     line,
+		Env:      env,
+	})
+}
+
+// Depth returns the number of active function calls.
+func (i *Interpreter) Depth() int {
+	return len(i.frames)
+}
+
+func (i *Interpreter) Globals() *Environment {
+	return i.global
+}
+
+// EvaluateIn evaluates the expression with the given environment as the
+// innermost scope. The hook is not notified while evaluating and runtime
+// errors are returned rather than propagated.
+func (i *Interpreter) EvaluateIn(env *Environment, expr ast.Expr) (value interface{}, err error) {
+	enclosingEnv, hook, frames, stmt := i.env, i.hook, len(i.frames), i.stmt
+	i.env = env
+	i.SetHook(nil)
+	defer func() {
+		i.env, i.frames, i.stmt = enclosingEnv, i.frames[:frames], stmt
+		i.SetHook(hook)
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	return i.Evaluate(expr), nil
+}
+
+func (i *Interpreter) pushFrame(function Caller, call lexing.Token) {
+	f := frame{
+		function: functionName(function, call),
+		call:     call,
+		env:      i.env,
+	}
+	i.frames = append(i.frames, f)
+
+	if i.callHook != nil {
+		i.callHook.EnterCall(f.describe(function))
+	}
+}
+
+func (i *Interpreter) popFrame(function Caller) {
+	f := i.frames[len(i.frames)-1]
+	i.frames = i.frames[:len(i.frames)-1]
+
+	if i.callHook != nil {
+		i.callHook.ExitCall(f.describe(function))
+	}
+}
+
+func (f frame) describe(function Caller) Call {
+	call := Call{
+		Function: f.function,
+		Line:     f.call.Line,
+	}
+
+	switch function.(type) {
+	case Function:
+		call.Declared = function.(Function).Declaration.Span().Start.Line
+	case LambdaFunction:
+		call.Declared = function.(LambdaFunction).LambdaExpr.Span().Start.Line
+	default:
+		call.Native = true
+	}
+	return call
+}
+
+func functionName(function Caller, call lexing.Token) string {
+	switch function.(type) {
+	case Function:
+		return function.(Function).Declaration.Name.Lexeme
+	case LambdaFunction:
+		return fmt.Sprintf("<lambda:%d>", function.(LambdaFunction).LambdaExpr.Span().Start.Line)
+	}
+	return call.Lexeme
+}
@@ -0,0 +1,215 @@
+package runtime
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// input is the reader natives read the program input from. Once readKey
+// is called the input is read in the background so that keys can be polled
+// without blocking, lines are then assembled from the pending keys.
+type input struct {
+	reader *bufio.Reader
+	file   *os.File
+
+	keys chan rune
+	stop chan struct{}
+	raw  *terminalState
+}
+
+func newInput(reader io.Reader) *input {
+	in := &input{reader: bufio.NewReader(reader)}
+	in.file, _ = reader.(*os.File)
+	return in
+}
+
+// SetInput sets the reader the program reads its input from, which is the
+// standard input by default.
+func (i *Interpreter) SetInput(in io.Reader) {
+	i.in.restore()
+	i.in.close()
+	i.in = newInput(in)
+}
+
+func (in *input) readRune() (rune, error) {
+	if in.keys == nil {
+		r, _, err := in.reader.ReadRune()
+		return r, err
+	}
+
+	r, ok := <-in.keys
+	if !ok {
+		return 0, io.EOF
+	}
+	return r, nil
+}
+
+// readLine returns the next line without its line ending, it reports false
+// at the end of the input.
+func (in *input) readLine() (string, bool, error) {
+	if in.keys == nil {
+		line, err := in.reader.ReadString('\n')
+		if err == io.EOF {
+			return line, line != "", nil
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r"), true, err
+	}
+
+	var line strings.Builder
+	for {
+		r, err := in.readRune()
+		if err == io.EOF {
+			return line.String(), line.Len() != 0, nil
+		}
+		if r == '\n' || r == '\r' && in.raw != nil {
+			return line.String(), true, nil
+		}
+		line.WriteRune(r)
+	}
+}
+
+func (in *input) readAll() (string, error) {
+	if in.keys == nil {
+		content, err := io.ReadAll(in.reader)
+		return string(content), err
+	}
+
+	var content strings.Builder
+	for r := range in.keys {
+		content.WriteRune(r)
+	}
+	return content.String(), nil
+}
+
+// keyTimeout is how long readKey waits for the rest of an escape sequence.
+const keyTimeout = 10 * time.Millisecond
+
+// readKey returns the next key pressed, or false if there is none yet.
+func (in *input) readKey() (string, bool) {
+	if in.keys == nil {
+		in.keys = make(chan rune, 64)
+		in.stop = make(chan struct{})
+		go func(reader *bufio.Reader, keys chan<- rune, stop <-chan struct{}) {
+			defer close(keys)
+			for {
+				r, _, err := reader.ReadRune()
+				if err != nil {
+					return
+				}
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				select {
+				case keys <- r:
+				case <-stop:
+					return
+				}
+			}
+		}(in.reader, in.keys, in.stop)
+	}
+
+	var r rune
+	select {
+	case key, ok := <-in.keys:
+		if !ok {
+			return "", false
+		}
+		r = key
+	default:
+		return "", false
+	}
+
+	switch r {
+	case '\x1b':
+		return in.escapeSequence(), true
+	case '\r', '\n':
+		return "enter", true
+	case '\t':
+		return "tab", true
+	case '\x7f', '\b':
+		return "backspace", true
+	}
+	return string(r), true
+}
+
+var escapeSequences = map[string]string{
+	"[A": "up",
+	"[B": "down",
+	"[C": "right",
+	"[D": "left",
+	"[H": "home",
+	"[F": "end",
+}
+
+// escapeSequence reads the rest of a key sent as an escape sequence, a
+// lone escape is the escape key.
+func (in *input) escapeSequence() string {
+	var sequence strings.Builder
+	timeout := time.After(keyTimeout)
+	for sequence.Len() < 2 {
+		select {
+		case r, ok := <-in.keys:
+			if !ok {
+				return "escape"
+			}
+			sequence.WriteRune(r)
+		case <-timeout:
+			return "escape"
+		}
+	}
+
+	if name, ok := escapeSequences[sequence.String()]; ok {
+		return name
+	}
+	return "escape"
+}
+
+// setRaw switches the terminal the input is read from in and out of raw
+// mode, where keys are available as soon as they are pressed and are not
+// echoed.
+func (in *input) setRaw(enabled bool) error {
+	if in.file == nil {
+		return errors.New("input is not a terminal")
+	}
+
+	if !enabled {
+		in.restore()
+		return nil
+	}
+	if in.raw != nil {
+		return nil
+	}
+
+	state, err := makeRaw(in.file)
+	if err != nil {
+		return fmt.Errorf("input is not a terminal: %v", err)
+	}
+	in.raw = state
+	return nil
+}
+
+// close stops the background reader started by readKey. A read in
+// progress can't be interrupted, the reader returns once it completes, so
+// a terminal stays read until the next key press or the end of the input.
+// The input then ends for the program.
+func (in *input) close() {
+	if in != nil && in.stop != nil {
+		close(in.stop)
+		in.stop = nil
+	}
+}
+
+// restore leaves raw mode if it was enabled.
+func (in *input) restore() {
+	if in != nil && in.raw != nil {
+		restoreTerminal(in.file, in.raw)
+		in.raw = nil
+	}
+}
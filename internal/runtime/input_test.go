@@ -0,0 +1,41 @@
+package runtime
+
+import (
+	"context"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"io"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestInterpretStopsKeyReader(t *testing.T) {
+	lexer := lexing.NewLexer("print readKey();\n")
+	lexer.ScanTokens()
+	statements := parsing.NewParser(lexer.Tokens, lexer.Lines).Parse()
+
+	reader, writer := io.Pipe()
+	defer writer.Close()
+	interpreter := NewInterpreter()
+	interpreter.SetInput(reader)
+	interpreter.SetOutput(ioutil.Discard)
+	if err := interpreter.Interpret(context.Background(), statements); err != nil {
+		t.Fatalf("Interpret: %v", err)
+	}
+	keys := interpreter.in.keys
+
+	// The reader is blocked on the pipe, it stops once the write completes
+	// and drops the key instead of forwarding it.
+	if _, err := writer.Write([]byte("a")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	select {
+	case key, ok := <-keys:
+		if ok {
+			t.Errorf("key %q forwarded after Interpret returned", key)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("key reader still running after Interpret returned")
+	}
+}
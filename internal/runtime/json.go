@@ -0,0 +1,191 @@
+package runtime
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"unicode/utf8"
+)
+
+type JSONParseFunc struct {
+}
+
+// Call converts JSON objects to maps, arrays to arrays, numbers to numbers
+// and null to nil.
+func (f JSONParseFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	text, ok := arguments[0].(string)
+	if !ok {
+		runtimeError(interpreter.callToken, "jsonParse expect string argument")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		runtimeError(interpreter.callToken, "jsonParse: "+jsonErrorMessage(text, err))
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(text))
+	return fromJSON(value)
+}
+
+func (f JSONParseFunc) ParametersCount() int {
+	return 1
+}
+
+func (f JSONParseFunc) ParameterNames() []string {
+	return []string{"text"}
+}
+
+// fromJSON converts the arrays in a value decoded by encoding/json to
+// array headers.
+func fromJSON(value interface{}) interface{} {
+	switch value.(type) {
+	case []interface{}:
+		elements := value.([]interface{})
+		for index, element := range elements {
+			elements[index] = fromJSON(element)
+		}
+		return NewArray(elements)
+	case map[string]interface{}:
+		entries := value.(map[string]interface{})
+		for key, entry := range entries {
+			entries[key] = fromJSON(entry)
+		}
+	}
+	return value
+}
+
+// jsonErrorMessage locates a syntax error in the JSON text by line and
+// column, the column counts characters from 1.
+func jsonErrorMessage(text string, err error) string {
+	var syntaxError *json.SyntaxError
+	if !errors.As(err, &syntaxError) {
+		return err.Error()
+	}
+
+	// The offset is past the offending character, or at the end of the
+	// text if it ended too early.
+	before := text
+	if offset := int(syntaxError.Offset); offset > 0 && offset <= len(text) &&
+		!strings.HasPrefix(syntaxError.Error(), "unexpected end") {
+		before = text[:offset-1]
+	}
+
+	line := strings.Count(before, "\n") + 1
+	column := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return fmt.Sprintf("%v at line %d, column %d", syntaxError, line, column)
+}
+
+// maxJSONIndent bounds the indent of jsonStringify, which is repeated on
+// every line of the output.
+const maxJSONIndent = 10
+
+type JSONStringifyFunc struct {
+}
+
+// Call serializes the value as JSON, with its elements indented by the
+// optional indent which is a number of spaces or a string. Map keys are
+// sorted.
+func (f JSONStringifyFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	indent := ""
+	if len(arguments) == 2 {
+		switch arguments[1].(type) {
+		case nil:
+		case float64:
+			spaces := arguments[1].(float64)
+			if spaces != math.Trunc(spaces) || spaces < 0 || spaces > maxJSONIndent {
+				runtimeError(interpreter.callToken, fmt.Sprintf(
+					"jsonStringify expect indent of 0 to %d spaces, got %s", maxJSONIndent, Repr(spaces)))
+			}
+			indent = strings.Repeat(" ", int(spaces))
+		case string:
+			indent = arguments[1].(string)
+			if len(indent) > maxJSONIndent {
+				runtimeError(interpreter.callToken, fmt.Sprintf(
+					"jsonStringify expect indent of at most %d characters", maxJSONIndent))
+			}
+		default:
+			runtimeError(interpreter.callToken, "jsonStringify expect number or string indent")
+		}
+	}
+
+	if err := checkJSONValue(arguments[0], "value", make(map[uintptr]bool)); err != nil {
+		runtimeError(interpreter.callToken, "jsonStringify: "+err.Error())
+	}
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", indent)
+	if err := encoder.Encode(arguments[0]); err != nil {
+		runtimeError(interpreter.callToken, "jsonStringify: "+err.Error())
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, buffer.Len())
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+func (f JSONStringifyFunc) ParametersCount() int {
+	return -1
+}
+
+func (f JSONStringifyFunc) Arity() (int, int) {
+	return 1, 2
+}
+
+func (f JSONStringifyFunc) ParameterNames() []string {
+	return []string{"value", "indent"}
+}
+
+// checkJSONValue reports values JSON can't represent, path locates the
+// value and visiting holds the arrays and maps containing it to detect
+// cycles.
+func checkJSONValue(value interface{}, path string, visiting map[uintptr]bool) error {
+	switch value.(type) {
+	case nil, bool, string:
+		return nil
+	case float64:
+		if math.IsNaN(value.(float64)) || math.IsInf(value.(float64), 0) {
+			return fmt.Errorf("%s is %v which is not serializable", path, value)
+		}
+		return nil
+	case *Array:
+		return checkJSONContainer(value, path, visiting, func() error {
+			for index, element := range value.(*Array).Elements {
+				if err := checkJSONValue(element, fmt.Sprintf("%s[%d]", path, index), visiting); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	case map[string]interface{}:
+		entries := value.(map[string]interface{})
+		return checkJSONContainer(value, path, visiting, func() error {
+			for _, key := range sortedKeys(entries) {
+				if err := checkJSONValue(entries[key], fmt.Sprintf("%s[%q]", path, key), visiting); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return fmt.Errorf("%s is %s which is not serializable", path, Repr(value))
+}
+
+func checkJSONContainer(value interface{}, path string, visiting map[uintptr]bool, checkElements func() error) error {
+	pointer := reflect.ValueOf(value).Pointer()
+	if pointer == 0 {
+		return checkElements()
+	}
+	if visiting[pointer] {
+		return fmt.Errorf("%s contains itself", path)
+	}
+
+	visiting[pointer] = true
+	defer delete(visiting, pointer)
+	return checkElements()
+}
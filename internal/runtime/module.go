@@ -0,0 +1,31 @@
+package runtime
+
+// Object is a value with properties, they are read with the dot operator.
+type Object interface {
+	Get(name string) (interface{}, bool)
+}
+
+// Module groups natives under a global name, such as fs.readFile.
+type Module struct {
+	Name    string
+	Members map[string]interface{}
+}
+
+func (m *Module) Get(name string) (interface{}, bool) {
+	value, ok := m.Members[name]
+	return value, ok
+}
+
+var modules = map[string]*Module{
+	"fs": fsModule,
+}
+
+// disabled returns a copy of the module whose natives raise an error
+// naming the capability they require.
+func (m *Module) disabled(capability Capability) *Module {
+	members := make(map[string]interface{}, len(m.Members))
+	for name := range m.Members {
+		members[name] = DisabledFunc{Name: m.Name + "." + name, Capability: capability}
+	}
+	return &Module{Name: m.Name, Members: members}
+}
@@ -2,7 +2,6 @@ package runtime
 
 import (
 	"fmt"
-	"github.com/paw1a/golox/internal/lexing"
 	"math/rand"
 	"os"
 	"strings"
@@ -27,10 +26,9 @@ func (f ExitFunc) Call(interpreter *Interpreter, arguments []interface{}) interf
 	arg0 := arguments[0]
 
 	if isNumber(arg0) {
-		exitCode := int(arg0.(float64))
-		os.Exit(exitCode)
+		panic(&ExitError{Code: int(arg0.(float64))})
 	} else {
-		runtimeError(lexing.Token{}, "exit code must be integer number")
+		runtimeError(interpreter.callToken, "exit code must be integer number")
 	}
 	return nil
 }
@@ -45,11 +43,16 @@ type AppendFunc struct {
 func (f AppendFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
 	arg0 := arguments[0]
 	switch arg0.(type) {
-	case []interface{}:
-		return append(arg0.([]interface{}), arguments[1])
+	case *Array:
+		array := arg0.(*Array)
+		if array.frozen {
+			runtimeError(interpreter.callToken, "cannot append to frozen array")
+		}
+		interpreter.allocate(interpreter.callToken.Line, (len(array.Elements)+1)*valueSize)
+		return NewArray(append(array.Elements, arguments[1]))
 	}
 
-	runtimeError(lexing.Token{}, "append func expect array argument first")
+	runtimeError(interpreter.callToken, "append func expect array argument first")
 	return nil
 }
 
@@ -57,17 +60,42 @@ func (f AppendFunc) ParametersCount() int {
 	return 2
 }
 
+type FreezeFunc struct {
+}
+
+// Call makes the array immutable and returns it. The elements are copied,
+// arrays appended to it before may share their storage.
+func (f FreezeFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	array, ok := arguments[0].(*Array)
+	if !ok {
+		runtimeError(interpreter.callToken, "freeze func expect array argument")
+	}
+
+	if !array.frozen {
+		interpreter.allocate(interpreter.callToken.Line, len(array.Elements)*valueSize)
+		array.Elements = append([]interface{}{}, array.Elements...)
+		array.frozen = true
+	}
+	return array
+}
+
+func (f FreezeFunc) ParametersCount() int {
+	return 1
+}
+
 type LenFunc struct {
 }
 
 func (f LenFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
 	arg0 := arguments[0]
 	switch arg0.(type) {
-	case []interface{}:
-		return float64(len(arg0.([]interface{})))
+	case *Array:
+		return float64(len(arg0.(*Array).Elements))
+	case map[string]interface{}:
+		return float64(len(arg0.(map[string]interface{})))
 	}
 
-	runtimeError(lexing.Token{}, "len func expect array argument")
+	runtimeError(interpreter.callToken, "len func expect array or map argument")
 	return nil
 }
 
@@ -75,18 +103,55 @@ func (f LenFunc) ParametersCount() int {
 	return 1
 }
 
+type KeysFunc struct {
+}
+
+// Call returns the keys of the map, sorted.
+func (f KeysFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	entries, ok := arguments[0].(map[string]interface{})
+	if !ok {
+		runtimeError(interpreter.callToken, "keys func expect map argument")
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(entries)*valueSize)
+	keys := make([]interface{}, 0, len(entries))
+	for _, key := range sortedKeys(entries) {
+		keys = append(keys, key)
+	}
+	return NewArray(keys)
+}
+
+func (f KeysFunc) ParametersCount() int {
+	return 1
+}
+
 type PrintFunc struct {
 }
 
+// Call writes the arguments formatted as format does, or as the verbs of
+// Go's fmt package would if the format uses them.
 func (f PrintFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	if len(arguments) == 0 {
+		runtimeError(interpreter.callToken, "printf expect format string at first argument")
+	}
+
 	switch arguments[0].(type) {
 	case string:
-		arguments[0] = strings.ReplaceAll(arguments[0].(string), `\n`, "\n")
-		fmt.Printf(arguments[0].(string), arguments[1:]...)
+		format := strings.ReplaceAll(arguments[0].(string), `\n`, "\n")
+		formatArguments := formatString
+		if isLegacyFormat(format) {
+			formatArguments = legacyFormatString
+		}
+
+		text, err := formatArguments(format, arguments[1:])
+		if err != nil {
+			runtimeError(interpreter.callToken, "printf: "+err.Error())
+		}
+		fmt.Fprint(interpreter.out, text)
 		return nil
 	}
 
-	runtimeError(lexing.Token{}, "printf expect format string at first argument")
+	runtimeError(interpreter.callToken, "printf expect format string at first argument")
 	return nil
 }
 
@@ -94,17 +159,51 @@ func (f PrintFunc) ParametersCount() int {
 	return -1
 }
 
+type FormatFunc struct {
+}
+
+func (f FormatFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	if len(arguments) == 0 {
+		runtimeError(interpreter.callToken, "format expect format string at first argument")
+	}
+
+	format, ok := arguments[0].(string)
+	if !ok {
+		runtimeError(interpreter.callToken, "format expect format string at first argument")
+	}
+
+	text, err := formatString(strings.ReplaceAll(format, `\n`, "\n"), arguments[1:])
+	if err != nil {
+		runtimeError(interpreter.callToken, "format: "+err.Error())
+	}
+	interpreter.allocate(interpreter.callToken.Line, len(text))
+	return text
+}
+
+func (f FormatFunc) ParametersCount() int {
+	return -1
+}
+
 type SleepFunc struct {
 }
 
 func (f SleepFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
 	arg0 := arguments[0]
 	if isNumber(arg0) {
-		time.Sleep(time.Duration(arg0.(float64)) * time.Millisecond)
+		duration := time.Duration(arg0.(float64)) * time.Millisecond
+		timer := time.NewTimer(duration)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-done(interpreter.ctx):
+			interpreter.checkContext()
+		case <-done(interpreter.interpretCtx):
+			interpreter.checkContext()
+		}
 		return nil
 	}
 
-	runtimeError(lexing.Token{}, "sleep expect number argument")
+	runtimeError(interpreter.callToken, "sleep expect number argument")
 	return nil
 }
 
@@ -116,7 +215,7 @@ type ClearFunc struct {
 }
 
 func (f ClearFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
-	fmt.Print("\033[2J")
+	fmt.Fprint(interpreter.out, "\033[2J")
 	return nil
 }
 
@@ -133,10 +232,154 @@ func (f RandomIntFunc) Call(interpreter *Interpreter, arguments []interface{}) i
 		return float64(rand.Intn(int(arg0.(float64))))
 	}
 
-	runtimeError(lexing.Token{}, "sleep expect number argument")
+	runtimeError(interpreter.callToken, "randint expect number argument")
 	return nil
 }
 
 func (f RandomIntFunc) ParametersCount() int {
 	return 1
 }
+
+type InputFunc struct {
+}
+
+// Call writes the prompt and reads a line, it returns nil at the end of
+// the input.
+func (f InputFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	prompt, ok := arguments[0].(string)
+	if !ok {
+		runtimeError(interpreter.callToken, "input expect string prompt")
+	}
+	fmt.Fprint(interpreter.out, prompt)
+
+	return readLine(interpreter, "input")
+}
+
+func (f InputFunc) ParametersCount() int {
+	return 1
+}
+
+type ReadLineFunc struct {
+}
+
+// Call reads a line without its line ending, it returns nil at the end of
+// the input.
+func (f ReadLineFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	return readLine(interpreter, "readLine")
+}
+
+func (f ReadLineFunc) ParametersCount() int {
+	return 0
+}
+
+func readLine(interpreter *Interpreter, name string) interface{} {
+	line, ok, err := interpreter.in.readLine()
+	if err != nil {
+		runtimeError(interpreter.callToken, fmt.Sprintf("%s: %v", name, err))
+	}
+	if !ok {
+		return nil
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(line))
+	return line
+}
+
+type ReadAllFunc struct {
+}
+
+func (f ReadAllFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	content, err := interpreter.in.readAll()
+	if err != nil {
+		runtimeError(interpreter.callToken, fmt.Sprintf("readAll: %v", err))
+	}
+
+	interpreter.allocate(interpreter.callToken.Line, len(content))
+	return content
+}
+
+func (f ReadAllFunc) ParametersCount() int {
+	return 0
+}
+
+type ReadKeyFunc struct {
+}
+
+// Call returns the key pressed since the last call without waiting, or nil
+// if there is none. Special keys are named: "up", "down", "left", "right",
+// "enter", "escape", "tab" and "backspace". The input is read in the
+// background from the first call until the program ends.
+func (f ReadKeyFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	key, ok := interpreter.in.readKey()
+	if !ok {
+		return nil
+	}
+	return key
+}
+
+func (f ReadKeyFunc) ParametersCount() int {
+	return 0
+}
+
+type RawModeFunc struct {
+}
+
+// Call switches the terminal in and out of raw mode, the terminal is
+// restored when the program ends.
+func (f RawModeFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	enabled, ok := arguments[0].(bool)
+	if !ok {
+		runtimeError(interpreter.callToken, "rawMode expect boolean argument")
+	}
+
+	if err := interpreter.in.setRaw(enabled); err != nil {
+		runtimeError(interpreter.callToken, fmt.Sprintf("rawMode: %v", err))
+	}
+	return nil
+}
+
+func (f RawModeFunc) ParametersCount() int {
+	return 1
+}
+
+type GetenvFunc struct {
+}
+
+// Call returns the value of the environment variable, or nil if it is not
+// set.
+func (f GetenvFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	name, ok := arguments[0].(string)
+	if !ok {
+		runtimeError(interpreter.callToken, "getenv expect string argument")
+	}
+
+	value, ok := os.LookupEnv(name)
+	if !ok {
+		return nil
+	}
+	return value
+}
+
+func (f GetenvFunc) ParametersCount() int {
+	return 1
+}
+
+type SetenvFunc struct {
+}
+
+func (f SetenvFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	name, ok := arguments[0].(string)
+	value, isString := arguments[1].(string)
+	if !ok || !isString {
+		runtimeError(interpreter.callToken, "setenv expect string arguments")
+	}
+
+	if err := os.Setenv(name, value); err != nil {
+		runtimeError(interpreter.callToken, fmt.Sprintf("setenv '%s': %v", name, err))
+	}
+	return nil
+}
+
+func (f SetenvFunc) ParametersCount() int {
+	return 2
+}
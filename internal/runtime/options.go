@@ -0,0 +1,185 @@
+package runtime
+
+import (
+	"context"
+	"fmt"
+	"github.com/paw1a/golox/internal/lexing"
+)
+
+// Option configures an interpreter, most options set limits guarding a host
+// against untrusted scripts.
+type Option func(*Interpreter)
+
+// defaultMaxCallDepth keeps deep recursion from overflowing the Go stack,
+// which would kill the process rather than raise a Lox error.
+const defaultMaxCallDepth = 10000
+
+// valueSize is the size accounted for every array element.
+const valueSize = 16
+
+// contextCheckInterval is the number of statements executed between two
+// checks of the context.
+const contextCheckInterval = 256
+
+// WithArgs sets the args global, the arguments the program was run with.
+func WithArgs(args ...string) Option {
+	return func(i *Interpreter) {
+		i.args = args
+	}
+}
+
+// WithMaxSteps limits the number of statements the interpreter executes.
+func WithMaxSteps(steps int) Option {
+	return func(i *Interpreter) {
+		i.maxSteps = steps
+	}
+}
+
+// WithMaxCallDepth limits the depth of nested calls, a call beyond it
+// raises a stack overflow error. A depth of zero removes the limit.
+func WithMaxCallDepth(depth int) Option {
+	return func(i *Interpreter) {
+		i.maxCallDepth = depth
+	}
+}
+
+// WithContext stops the program once the context is done, which gives a
+// wall-clock timeout with context.WithTimeout. It applies to statements
+// run with Execute, which then panics with ErrCancelled or
+// ErrDeadlineExceeded, and to Interpret along with its own context.
+func WithContext(ctx context.Context) Option {
+	return func(i *Interpreter) {
+		i.ctx = ctx
+	}
+}
+
+// WithMaxAllocation limits the total number of bytes the program allocates
+// for arrays and strings over its whole run.
+func WithMaxAllocation(bytes int) Option {
+	return func(i *Interpreter) {
+		i.maxAllocation = bytes
+	}
+}
+
+// WithCapabilities grants only the given capabilities, natives requiring
+// any other one raise an error when called. Every capability is granted
+// by default.
+func WithCapabilities(capabilities ...Capability) Option {
+	return func(i *Interpreter) {
+		i.capabilities = make(map[Capability]bool)
+		for _, capability := range capabilities {
+			i.capabilities[capability] = true
+		}
+	}
+}
+
+// Capability is a group of natives having effects outside of the program.
+type Capability string
+
+const (
+	ExitCapability     Capability = "exit"
+	SleepCapability    Capability = "sleep"
+	TerminalCapability Capability = "terminal"
+	FileCapability     Capability = "file"
+	InputCapability    Capability = "input"
+	EnvCapability      Capability = "env"
+)
+
+var nativeCapabilities = map[string]Capability{
+	"exit":     ExitCapability,
+	"sleep":    SleepCapability,
+	"clear":    TerminalCapability,
+	"rawMode":  TerminalCapability,
+	"fs":       FileCapability,
+	"input":    InputCapability,
+	"readLine": InputCapability,
+	"readAll":  InputCapability,
+	"readKey":  InputCapability,
+	"getenv":   EnvCapability,
+	"setenv":   EnvCapability,
+}
+
+func (i *Interpreter) granted(name string) (Capability, bool) {
+	capability, ok := nativeCapabilities[name]
+	if !ok || i.capabilities == nil {
+		return capability, true
+	}
+	return capability, i.capabilities[capability]
+}
+
+// DisabledFunc stands for a native whose capability was not granted.
+type DisabledFunc struct {
+	Name       string
+	Capability Capability
+}
+
+func (f DisabledFunc) Call(interpreter *Interpreter, arguments []interface{}) interface{} {
+	runtimeError(interpreter.callToken,
+		fmt.Sprintf("%s is disabled, it requires the %s capability", f.Name, f.Capability))
+	return nil
+}
+
+func (f DisabledFunc) ParametersCount() int {
+	return -1
+}
+
+// step accounts for the execution of a statement.
+func (i *Interpreter) step(line int) {
+	i.steps++
+	if i.maxSteps > 0 && i.steps > i.maxSteps {
+		lineError(line, "step limit exceeded")
+	}
+
+	if i.steps%contextCheckInterval == 0 {
+		i.checkContext()
+	}
+}
+
+// checkContext stops the program if one of its contexts is done. Unlike
+// runtime errors, the cancellation can't be caught by the program.
+func (i *Interpreter) checkContext() {
+	for _, ctx := range []context.Context{i.ctx, i.interpretCtx} {
+		if ctx == nil {
+			continue
+		}
+
+		switch ctx.Err() {
+		case nil:
+		case context.DeadlineExceeded:
+			panic(ErrDeadlineExceeded)
+		default:
+			panic(ErrCancelled)
+		}
+	}
+}
+
+// done returns the done channel of the context, nil if there is none so
+// that receiving from it blocks forever.
+func done(ctx context.Context) <-chan struct{} {
+	if ctx == nil {
+		return nil
+	}
+	return ctx.Done()
+}
+
+func (i *Interpreter) enterCall(paren lexing.Token) {
+	if i.maxCallDepth > 0 && i.callDepth >= i.maxCallDepth {
+		runtimeError(paren, "stack overflow")
+	}
+	i.callDepth++
+}
+
+// exitCall restores the state saved when entering the call, it is
+// deferred so that errors and exit unwind the interpreter cleanly.
+func (i *Interpreter) exitCall(enclosingCall lexing.Token) {
+	i.callDepth--
+	i.callToken = enclosingCall
+}
+
+// allocate accounts for bytes allocated by the program.
+func (i *Interpreter) allocate(line int, bytes int) {
+	i.allocated += bytes
+	if i.maxAllocation > 0 && i.allocated > i.maxAllocation {
+		lineError(line, "allocation limit exceeded")
+	}
+}
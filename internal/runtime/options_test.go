@@ -0,0 +1,73 @@
+package runtime
+
+import (
+	"context"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+func TestInterpretContexts(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancelExpired := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancelExpired()
+	<-expired.Done()
+	derived, cancelDerived := context.WithCancel(context.WithValue(context.Background(), struct{}{}, 1))
+	cancelDerived()
+
+	tests := []struct {
+		name      string
+		option    context.Context
+		interpret context.Context
+		want      error
+	}{
+		{"no context", nil, context.Background(), nil},
+		{"option cancelled", cancelled, context.Background(), ErrCancelled},
+		{"option cancelled with todo", cancelled, context.TODO(), ErrCancelled},
+		{"option expired", expired, context.Background(), ErrDeadlineExceeded},
+		{"argument cancelled", nil, cancelled, ErrCancelled},
+		{"argument derived", context.Background(), derived, ErrCancelled},
+		{"argument expired", context.TODO(), expired, ErrDeadlineExceeded},
+		{"both live", context.Background(), context.TODO(), nil},
+	}
+
+	lexer := lexing.NewLexer("var i = 0;\nwhile (i < 1000) i = i + 1;\n")
+	lexer.ScanTokens()
+	statements := parsing.NewParser(lexer.Tokens, lexer.Lines).Parse()
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var options []Option
+			if test.option != nil {
+				options = append(options, WithContext(test.option))
+			}
+			interpreter := NewInterpreter(options...)
+			interpreter.SetOutput(ioutil.Discard)
+
+			if err := interpreter.Interpret(test.interpret, statements); err != test.want {
+				t.Errorf("Interpret = %v, want %v", err, test.want)
+			}
+		})
+	}
+}
+
+func TestSleepStopsWithInterpretContext(t *testing.T) {
+	lexer := lexing.NewLexer("sleep(10000);\n")
+	lexer.ScanTokens()
+	statements := parsing.NewParser(lexer.Tokens, lexer.Lines).Parse()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	interpreter := NewInterpreter(WithContext(context.Background()))
+
+	start := time.Now()
+	if err := interpreter.Interpret(ctx, statements); err != ErrDeadlineExceeded {
+		t.Errorf("Interpret = %v, want %v", err, ErrDeadlineExceeded)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("sleep ran for %s after the deadline", elapsed)
+	}
+}
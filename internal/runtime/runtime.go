@@ -1,8 +1,13 @@
 package runtime
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"github.com/paw1a/golox/internal/ast"
 	"github.com/paw1a/golox/internal/lexing"
+	"io"
+	"os"
 )
 
 type Interpreter struct {
@@ -10,6 +15,36 @@ type Interpreter struct {
 	global        *Environment
 	loopContext   loopContext
 	returnContext returnContext
+
+	// callToken locates the call currently being evaluated, natives
+	// report their errors at it.
+	callToken lexing.Token
+
+	hook       Hook
+	callHook   CallHook
+	branchHook BranchHook
+	frames     []frame
+	stmt       ast.Stmt
+
+	in  *input
+	out io.Writer
+
+	// files are the files opened by the program and not closed yet.
+	files map[*File]bool
+
+	args []string
+
+	// ctx is the context given with WithContext and interpretCtx the one
+	// given to Interpret, the program stops once any of them is done.
+	ctx           context.Context
+	interpretCtx  context.Context
+	steps         int
+	maxSteps      int
+	callDepth     int
+	maxCallDepth  int
+	allocated     int
+	maxAllocation int
+	capabilities  map[Capability]bool
 }
 
 type loopContext struct {
@@ -22,14 +57,54 @@ type returnContext struct {
 	returnValue interface{}
 }
 
-func runtimeError(token lexing.Token, message string) {
+var (
+	ErrCancelled        = errors.New("execution cancelled")
+	ErrDeadlineExceeded = errors.New("execution deadline exceeded")
+)
+
+// Error is a runtime error of a Lox program, it is raised as a panic.
+type Error struct {
+	Token   lexing.Token
+	Message string
+
+	text string
+}
+
+func (e *Error) Error() string {
+	return e.text
+}
+
+func NewError(token lexing.Token, message string) *Error {
 	var errorMessage string
 	if token.TokenType == lexing.Eof {
 		errorMessage = fmt.Sprintf("line %d | at end of input: %s", token.Line, message)
 	} else {
 		errorMessage = fmt.Sprintf("line %d | at '%s': %s", token.Line, token.Lexeme, message)
 	}
-	panic(errorMessage)
+	return &Error{Token: token, Message: message, text: errorMessage}
+}
+
+func runtimeError(token lexing.Token, message string) {
+	panic(NewError(token, message))
+}
+
+// ExitError is raised by the exit native, it unwinds the program like a
+// runtime error but can't be caught by it.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// lineError raises an error which is not caused by a particular token.
+func lineError(line int, message string) {
+	panic(&Error{
+		Token:   lexing.Token{Line: line},
+		Message: message,
+		text:    fmt.Sprintf("line %d | %s", line, message),
+	})
 }
 
 func isTruthy(value interface{}) bool {
@@ -65,18 +140,128 @@ func isString(value interface{}) bool {
 	return false
 }
 
-func NewInterpreter() *Interpreter {
+var natives = map[string]Caller{
+	"clock":         ClockFunc{},
+	"exit":          ExitFunc{},
+	"append":        AppendFunc{},
+	"freeze":        FreezeFunc{},
+	"len":           LenFunc{},
+	"keys":          KeysFunc{},
+	"deepEqual":     DeepEqualFunc{},
+	"sort":          SortFunc{},
+	"printf":        PrintFunc{},
+	"format":        FormatFunc{},
+	"str":           StrFunc{},
+	"repr":          ReprFunc{},
+	"sleep":         SleepFunc{},
+	"clear":         ClearFunc{},
+	"randint":       RandomIntFunc{},
+	"input":         InputFunc{},
+	"readLine":      ReadLineFunc{},
+	"readAll":       ReadAllFunc{},
+	"readKey":       ReadKeyFunc{},
+	"rawMode":       RawModeFunc{},
+	"getenv":        GetenvFunc{},
+	"setenv":        SetenvFunc{},
+	"jsonParse":     JSONParseFunc{},
+	"jsonStringify": JSONStringifyFunc{},
+}
+
+// Builtins returns the native functions and modules every interpreter
+// starts with.
+func Builtins() map[string]interface{} {
+	builtins := make(map[string]interface{}, len(natives)+len(modules))
+	for name, native := range natives {
+		builtins[name] = native
+	}
+	for name, module := range modules {
+		builtins[name] = module
+	}
+	return builtins
+}
+
+// SetOutput redirects everything the program prints, which goes to the
+// standard output by default.
+func (i *Interpreter) SetOutput(out io.Writer) {
+	i.out = out
+}
+
+// Define adds a global, it is used to provide natives beyond the builtins.
+func (i *Interpreter) Define(name string, value interface{}) {
+	i.global.define(name, value)
+}
+
+// CallToken returns the token of the call being evaluated, natives defined
+// outside of this package report their errors at it.
+func (i *Interpreter) CallToken() lexing.Token {
+	return i.callToken
+}
+
+// Interpret executes the program until it ends or either ctx or the context
+// given with WithContext is done. It returns ErrCancelled or ErrDeadlineExceeded if the program was stopped
+// by the context, an *Error if the program failed and an *ExitError if it
+// called exit.
+func (i *Interpreter) Interpret(ctx context.Context, statements []ast.Stmt) (err error) {
+	enclosingCtx := i.interpretCtx
+	i.interpretCtx = ctx
+	defer func() {
+		i.interpretCtx = enclosingCtx
+		i.in.restore()
+		i.in.close()
+		i.closeFiles()
+		if r := recover(); r != nil {
+			switch r {
+			case ErrCancelled, ErrDeadlineExceeded:
+				err = r.(error)
+				return
+			}
+			switch r.(type) {
+			case *Error, *ExitError:
+				err = r.(error)
+				return
+			}
+			panic(r)
+		}
+	}()
+
+	i.checkContext()
+	for _, stmt := range statements {
+		i.Execute(stmt)
+	}
+	return nil
+}
+
+func NewInterpreter(options ...Option) *Interpreter {
 	global := NewEnvironment(nil)
-	global.define("clock", ClockFunc{})
-	global.define("exit", ExitFunc{})
-	global.define("append", AppendFunc{})
-	global.define("len", LenFunc{})
-	global.define("printf", PrintFunc{})
-	global.define("sleep", SleepFunc{})
-	global.define("clear", ClearFunc{})
-	global.define("randint", RandomIntFunc{})
-	return &Interpreter{
-		env:    global,
-		global: global,
+	i := &Interpreter{
+		env:          global,
+		global:       global,
+		in:           newInput(os.Stdin),
+		out:          os.Stdout,
+		files:        make(map[*File]bool),
+		maxCallDepth: defaultMaxCallDepth,
+	}
+	for _, option := range options {
+		option(i)
+	}
+
+	for name, native := range natives {
+		if capability, ok := i.granted(name); !ok {
+			native = DisabledFunc{Name: name, Capability: capability}
+		}
+		global.define(name, native)
+	}
+	for name, module := range modules {
+		if capability, ok := i.granted(name); !ok {
+			module = module.disabled(capability)
+		}
+		global.define(name, module)
+	}
+
+	args := make([]interface{}, len(i.args))
+	for index, arg := range i.args {
+		args[index] = arg
 	}
+	global.define("args", NewArray(args))
+	return i
 }
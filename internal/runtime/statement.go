@@ -1,16 +1,27 @@
 package runtime
 
 import (
+	"fmt"
 	"github.com/paw1a/golox/internal/ast"
 	"github.com/paw1a/golox/internal/lexing"
 )
 
 func (i *Interpreter) Execute(stmt ast.Stmt) {
+	i.step(stmt.Span().Start.Line)
+	if i.hook != nil {
+		i.stmt = stmt
+		i.hook.BeforeStmt(stmt)
+	}
+
 	switch stmt.(type) {
 	case ast.ExpressionStmt:
 		i.executeExprStmt(stmt.(ast.ExpressionStmt))
+	case ast.PrintStmt:
+		i.executePrintStmt(stmt.(ast.PrintStmt))
 	case ast.VarDeclarationStmt:
 		i.executeVarDeclarationStmt(stmt.(ast.VarDeclarationStmt))
+	case ast.VarPatternStmt:
+		i.executeVarPatternStmt(stmt.(ast.VarPatternStmt))
 	case ast.BlockStmt:
 		i.executeBlockStmt(stmt.(ast.BlockStmt))
 	case ast.IfStmt:
@@ -25,6 +36,8 @@ func (i *Interpreter) Execute(stmt ast.Stmt) {
 		i.executeFunDeclarationStmt(stmt.(ast.FunDeclarationStmt))
 	case ast.ReturnStmt:
 		i.executeReturnStmt(stmt.(ast.ReturnStmt))
+	case ast.MatchStmt:
+		i.executeMatchStmt(stmt.(ast.MatchStmt))
 	default:
 		runtimeError(lexing.Token{}, "invalid ast type")
 	}
@@ -34,15 +47,29 @@ func (i *Interpreter) executeExprStmt(stmt ast.ExpressionStmt) {
 	i.Evaluate(stmt.Expr)
 }
 
+func (i *Interpreter) executePrintStmt(stmt ast.PrintStmt) {
+	fmt.Fprintln(i.out, Str(i.Evaluate(stmt.Expr)))
+}
+
 func (i *Interpreter) executeVarDeclarationStmt(stmt ast.VarDeclarationStmt) {
 	var value interface{}
 	if stmt.Initializer != nil {
 		value = i.Evaluate(stmt.Initializer)
 	}
 
+	if stmt.Const {
+		i.env.defineConstant(stmt.Name.Lexeme, value)
+		return
+	}
 	i.env.define(stmt.Name.Lexeme, value)
 }
 
+func (i *Interpreter) executeVarPatternStmt(stmt ast.VarPatternStmt) {
+	i.destructure(stmt.Pattern, i.Evaluate(stmt.Initializer), func(target ast.Expr, value interface{}) {
+		i.env.define(target.(ast.VariableExpr).Name.Lexeme, value)
+	})
+}
+
 func (i *Interpreter) executeFunDeclarationStmt(stmt ast.FunDeclarationStmt) {
 	function := Function{
 		Declaration: stmt,
@@ -69,6 +96,9 @@ func (i *Interpreter) executeBlockStmt(blockStmt ast.BlockStmt) {
 
 func (i *Interpreter) executeIfStmt(stmt ast.IfStmt) {
 	conditionValue := i.Evaluate(stmt.ConditionExpr)
+	if i.branchHook != nil {
+		i.branchHook.Branch(stmt, isTruthy(conditionValue))
+	}
 
 	if isTruthy(conditionValue) {
 		i.Execute(stmt.IfStatement)
@@ -80,6 +110,17 @@ func (i *Interpreter) executeIfStmt(stmt ast.IfStmt) {
 	}
 }
 
+func (i *Interpreter) executeMatchStmt(stmt ast.MatchStmt) {
+	enclosingEnv := i.env
+	defer func() {
+		i.env = enclosingEnv
+	}()
+
+	if arm, ok := i.selectArm(i.Evaluate(stmt.Subject), stmt.Arms); ok {
+		i.Execute(arm.Body.(ast.Stmt))
+	}
+}
+
 func (i *Interpreter) executeForStmt(stmt ast.ForStmt) {
 	enclosingEnv := i.env
 	i.env = NewEnvironment(enclosingEnv)
@@ -91,7 +132,7 @@ func (i *Interpreter) executeForStmt(stmt ast.ForStmt) {
 		i.Execute(stmt.InitializerStmt)
 	}
 
-	for isTruthy(i.Evaluate(stmt.ConditionExpr)) {
+	for i.loopCondition(stmt) {
 		i.Execute(stmt.Statement)
 		if i.loopContext.breakFlag {
 			i.loopContext.breakFlag = false
@@ -107,6 +148,14 @@ func (i *Interpreter) executeForStmt(stmt ast.ForStmt) {
 	}
 }
 
+func (i *Interpreter) loopCondition(stmt ast.ForStmt) bool {
+	condition := isTruthy(i.Evaluate(stmt.ConditionExpr))
+	if i.branchHook != nil {
+		i.branchHook.Branch(stmt, condition)
+	}
+	return condition
+}
+
 func (i *Interpreter) executeBreakStmt() {
 	i.loopContext.breakFlag = true
 }
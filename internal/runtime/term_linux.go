@@ -0,0 +1,44 @@
+//go:build linux
+
+package runtime
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type terminalState struct {
+	termios syscall.Termios
+}
+
+func ioctl(file *os.File, request uintptr, termios *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), request, uintptr(unsafe.Pointer(termios)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+// makeRaw disables line buffering and echo of the terminal. Signals are
+// left enabled so that a program stuck in raw mode can be interrupted.
+func makeRaw(file *os.File) (*terminalState, error) {
+	var state terminalState
+	if err := ioctl(file, syscall.TCGETS, &state.termios); err != nil {
+		return nil, err
+	}
+
+	raw := state.termios
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.IEXTEN
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+	if err := ioctl(file, syscall.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+func restoreTerminal(file *os.File, state *terminalState) {
+	ioctl(file, syscall.TCSETS, &state.termios)
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+
+package runtime
+
+import (
+	"errors"
+	"os"
+)
+
+type terminalState struct {
+}
+
+func makeRaw(file *os.File) (*terminalState, error) {
+	return nil, errors.New("raw mode is only supported on linux")
+}
+
+func restoreTerminal(file *os.File, state *terminalState) {
+}
@@ -0,0 +1,187 @@
+package testrunner
+
+import (
+	"fmt"
+	"github.com/paw1a/golox/internal/runtime"
+	"strings"
+)
+
+// Failure is raised by the assertion natives when an assertion does not
+// hold.
+type Failure struct {
+	Line    int
+	Message string
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("line %d | %s", f.Line, f.Message)
+}
+
+func fail(interpreter *runtime.Interpreter, message string) {
+	panic(&Failure{Line: interpreter.CallToken().Line, Message: message})
+}
+
+var natives = map[string]runtime.Caller{
+	"assert":       AssertFunc{},
+	"assertEqual":  AssertEqualFunc{},
+	"assertThrows": AssertThrowsFunc{},
+}
+
+// message returns the optional message passed after the required
+// arguments of an assertion, the arity of the assertion is already checked.
+func message(interpreter *runtime.Interpreter, name string, arguments []interface{}, required int) string {
+	if len(arguments) == required || arguments[required] == nil {
+		return ""
+	}
+	if text, ok := arguments[required].(string); ok {
+		return text + ": "
+	}
+	panic(runtime.NewError(interpreter.CallToken(), name+" expect string message as last argument"))
+}
+
+type AssertFunc struct {
+}
+
+func (f AssertFunc) Call(interpreter *runtime.Interpreter, arguments []interface{}) interface{} {
+	prefix := message(interpreter, "assert", arguments, 1)
+	if arguments[0] != true {
+		fail(interpreter, prefix+fmt.Sprintf("assertion failed, got %s", runtime.Repr(arguments[0])))
+	}
+	return nil
+}
+
+func (f AssertFunc) ParametersCount() int {
+	return -1
+}
+
+func (f AssertFunc) Arity() (int, int) {
+	return 1, 2
+}
+
+func (f AssertFunc) ParameterNames() []string {
+	return []string{"condition", "message"}
+}
+
+type AssertEqualFunc struct {
+}
+
+func (f AssertEqualFunc) Call(interpreter *runtime.Interpreter, arguments []interface{}) interface{} {
+	prefix := message(interpreter, "assertEqual", arguments, 2)
+	actual, expected := arguments[0], arguments[1]
+	if !runtime.DeepEqual(actual, expected) {
+		fail(interpreter, prefix+"values are not equal\n"+describe(expected, actual))
+	}
+	return nil
+}
+
+func (f AssertEqualFunc) ParametersCount() int {
+	return -1
+}
+
+func (f AssertEqualFunc) Arity() (int, int) {
+	return 2, 3
+}
+
+func (f AssertEqualFunc) ParameterNames() []string {
+	return []string{"actual", "expected", "message"}
+}
+
+type AssertThrowsFunc struct {
+}
+
+// Call returns the message of the error raised by the function, so that
+// tests can make further assertions on it.
+func (f AssertThrowsFunc) Call(interpreter *runtime.Interpreter, arguments []interface{}) (message interface{}) {
+	function, ok := arguments[0].(runtime.Caller)
+	if !ok {
+		panic(runtime.NewError(interpreter.CallToken(), "assertThrows expect function argument"))
+	}
+
+	line := interpreter.CallToken().Line
+	defer func() {
+		if r := recover(); r != nil {
+			err, ok := r.(*runtime.Error)
+			if !ok {
+				panic(r)
+			}
+			message = err.Message
+		}
+	}()
+
+	interpreter.Call(function)
+	panic(&Failure{Line: line, Message: "expected an error, the function returned normally"})
+}
+
+func (f AssertThrowsFunc) ParametersCount() int {
+	return 1
+}
+
+// describe shows the difference between the values, as a line diff if any
+// of them spans several lines.
+func describe(expected interface{}, actual interface{}) string {
+	want, got := lines(expected), lines(actual)
+	if len(want) == 1 && len(got) == 1 {
+		return fmt.Sprintf("expected: %s\n  actual: %s", want[0], got[0])
+	}
+	return "--- expected\n+++ actual\n" + diff(want, got)
+}
+
+func lines(value interface{}) []string {
+	switch value.(type) {
+	case *runtime.Array:
+		array := value.(*runtime.Array).Elements
+		if len(array) == 0 {
+			return []string{"[]"}
+		}
+
+		lines := []string{"["}
+		for _, element := range array {
+			lines = append(lines, "  "+runtime.Repr(element)+",")
+		}
+		return append(lines, "]")
+	case string:
+		if strings.Contains(value.(string), "\n") {
+			return strings.Split(value.(string), "\n")
+		}
+	}
+	return []string{runtime.Repr(value)}
+}
+
+// diff is a line diff of a and b based on their longest common subsequence.
+func diff(a []string, b []string) string {
+	// common[i][j] is the length of the longest common subsequence of
+	// a[i:] and b[j:].
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else if common[i+1][j] >= common[i][j+1] {
+				common[i][j] = common[i+1][j]
+			} else {
+				common[i][j] = common[i][j+1]
+			}
+		}
+	}
+
+	var out strings.Builder
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			out.WriteString("  " + a[i] + "\n")
+			i++
+			j++
+		case i < len(a) && (j == len(b) || common[i+1][j] >= common[i][j+1]):
+			out.WriteString("- " + a[i] + "\n")
+			i++
+		default:
+			out.WriteString("+ " + b[j] + "\n")
+			j++
+		}
+	}
+	return strings.TrimSuffix(out.String(), "\n")
+}
@@ -0,0 +1,205 @@
+package testrunner
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteText writes the results in a go test like format. The output of a
+// test is shown if it did not pass, or always in verbose mode.
+func WriteText(w io.Writer, suites []Suite, verbose bool) error {
+	out := bufio.NewWriter(w)
+
+	var total Suite
+	var duration time.Duration
+	for _, suite := range suites {
+		duration += suite.Duration
+		if suite.Err != nil {
+			fmt.Fprintf(out, "ERROR %s\n%s\n", suite.File, indent(suite.Err.Error()))
+			continue
+		}
+
+		for _, result := range suite.Results {
+			total.Results = append(total.Results, result)
+			if result.Status == Pass && !verbose {
+				continue
+			}
+
+			fmt.Fprintf(out, "--- %s: %s %s (%s)\n", result.Status, suite.File, result.Name, formatDuration(result.Duration))
+			if result.Message != "" {
+				fmt.Fprintln(out, indent(result.Message))
+			}
+			if result.Output != "" {
+				fmt.Fprintln(out, indent("output:\n"+strings.TrimSuffix(result.Output, "\n")))
+			}
+		}
+
+		status := "ok"
+		if !suite.Passed() {
+			status = "FAIL"
+		}
+		fmt.Fprintf(out, "%-4s  %s  %d tests (%s)\n", status, suite.File, len(suite.Results), formatDuration(suite.Duration))
+	}
+
+	errors := total.Count(Error)
+	for _, suite := range suites {
+		if suite.Err != nil {
+			errors++
+		}
+	}
+	fmt.Fprintf(out, "\n%d passed, %d failed, %d errors in %s\n",
+		total.Count(Pass), total.Count(Fail), errors, formatDuration(duration))
+
+	return out.Flush()
+}
+
+// WriteTAP writes the results in the Test Anything Protocol version 13.
+func WriteTAP(w io.Writer, suites []Suite) error {
+	out := bufio.NewWriter(w)
+
+	count := 0
+	for _, suite := range suites {
+		if suite.Err != nil {
+			count++
+		}
+		count += len(suite.Results)
+	}
+
+	fmt.Fprintf(out, "TAP version 13\n1..%d\n", count)
+
+	number := 0
+	for _, suite := range suites {
+		if suite.Err != nil {
+			number++
+			fmt.Fprintf(out, "not ok %d - %s\n", number, suite.File)
+			writeDiagnostic(out, suite.Err.Error(), 0)
+			continue
+		}
+
+		for _, result := range suite.Results {
+			number++
+			status := "ok"
+			if result.Status != Pass {
+				status = "not ok"
+			}
+			fmt.Fprintf(out, "%s %d - %s %s\n", status, number, suite.File, result.Name)
+			if result.Status != Pass {
+				writeDiagnostic(out, result.Message, result.Duration)
+			}
+		}
+	}
+
+	return out.Flush()
+}
+
+func writeDiagnostic(out io.Writer, message string, duration time.Duration) {
+	fmt.Fprintln(out, "  ---")
+	fmt.Fprintln(out, "  message: |")
+	for _, line := range strings.Split(message, "\n") {
+		fmt.Fprintf(out, "    %s\n", line)
+	}
+	if duration != 0 {
+		fmt.Fprintf(out, "  duration_ms: %.3f\n", float64(duration.Microseconds())/1000)
+	}
+	fmt.Fprintln(out, "  ...")
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+	Error    *junitMessage   `xml:"error,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results as JUnit XML, one testsuite per file.
+func WriteJUnit(w io.Writer, suites []Suite) error {
+	var report junitTestSuites
+	for _, suite := range suites {
+		s := junitTestSuite{
+			Name:     suite.File,
+			Tests:    len(suite.Results),
+			Failures: suite.Count(Fail),
+			Errors:   suite.Count(Error),
+			Time:     formatSeconds(suite.Duration),
+		}
+		if suite.Err != nil {
+			s.Errors++
+			s.Error = newJUnitMessage(suite.Err.Error())
+		}
+
+		for _, result := range suite.Results {
+			c := junitTestCase{
+				Name:      result.Name,
+				Classname: suite.File,
+				Time:      formatSeconds(result.Duration),
+				SystemOut: result.Output,
+			}
+			switch result.Status {
+			case Fail:
+				c.Failure = newJUnitMessage(result.Message)
+			case Error:
+				c.Error = newJUnitMessage(result.Message)
+			}
+			s.Cases = append(s.Cases, c)
+		}
+
+		report.Suites = append(report.Suites, s)
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func newJUnitMessage(message string) *junitMessage {
+	summary := message
+	if index := strings.IndexByte(summary, '\n'); index >= 0 {
+		summary = summary[:index]
+	}
+	return &junitMessage{Message: summary, Text: message}
+}
+
+func indent(text string) string {
+	return "    " + strings.ReplaceAll(text, "\n", "\n    ")
+}
+
+func formatDuration(d time.Duration) string {
+	return d.Round(time.Microsecond).String()
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
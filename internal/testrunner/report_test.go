@@ -0,0 +1,113 @@
+package testrunner
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+	"time"
+)
+
+var reportSuites = []Suite{
+	{
+		File:     "math_test.lox",
+		Duration: 3 * time.Millisecond,
+		Results: []Result{
+			{Name: "test_add", Status: Pass, Output: "2\n", Duration: time.Millisecond},
+			{Name: "test_sub", Status: Fail, Message: "line 7 | values are not equal\nexpected: 1\n  actual: 2", Duration: 1500 * time.Microsecond},
+			{Name: "test_div", Status: Error, Message: "line 9 | division by zero", Output: "<&>\n"},
+		},
+	},
+	{
+		File:     "broken_test.lox",
+		Err:      errors.New("line 1 | expect expression"),
+		Duration: time.Millisecond,
+	},
+}
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTAP(&out, reportSuites); err != nil {
+		t.Fatalf("WriteTAP: %v", err)
+	}
+
+	want := `TAP version 13
+1..4
+ok 1 - math_test.lox test_add
+not ok 2 - math_test.lox test_sub
+  ---
+  message: |
+    line 7 | values are not equal
+    expected: 1
+      actual: 2
+  duration_ms: 1.500
+  ...
+not ok 3 - math_test.lox test_div
+  ---
+  message: |
+    line 9 | division by zero
+  ...
+not ok 4 - broken_test.lox
+  ---
+  message: |
+    line 1 | expect expression
+  ...
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteTAP =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, reportSuites); err != nil {
+		t.Fatalf("WriteJUnit: %v", err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<testsuites>
+  <testsuite name="math_test.lox" tests="3" failures="1" errors="1" time="0.003">
+    <testcase name="test_add" classname="math_test.lox" time="0.001">
+      <system-out>2&#xA;</system-out>
+    </testcase>
+    <testcase name="test_sub" classname="math_test.lox" time="0.002">
+      <failure message="line 7 | values are not equal">line 7 | values are not equal&#xA;expected: 1&#xA;  actual: 2</failure>
+    </testcase>
+    <testcase name="test_div" classname="math_test.lox" time="0.000">
+      <error message="line 9 | division by zero">line 9 | division by zero</error>
+      <system-out>&lt;&amp;&gt;&#xA;</system-out>
+    </testcase>
+  </testsuite>
+  <testsuite name="broken_test.lox" tests="0" failures="0" errors="1" time="0.001">
+    <error message="line 1 | expect expression">line 1 | expect expression</error>
+  </testsuite>
+</testsuites>
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteJUnit =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWriteText(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteText(&out, reportSuites, false); err != nil {
+		t.Fatalf("WriteText: %v", err)
+	}
+
+	want := `--- FAIL: math_test.lox test_sub (1.5ms)
+    line 7 | values are not equal
+    expected: 1
+      actual: 2
+--- ERROR: math_test.lox test_div (0s)
+    line 9 | division by zero
+    output:
+    <&>
+FAIL  math_test.lox  3 tests (3ms)
+ERROR broken_test.lox
+    line 1 | expect expression
+
+1 passed, 1 failed, 2 errors in 4ms
+`
+	if got := out.String(); got != want {
+		t.Errorf("WriteText =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,211 @@
+package testrunner
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/paw1a/golox/internal/ast"
+	"github.com/paw1a/golox/internal/lexing"
+	"github.com/paw1a/golox/internal/parsing"
+	"github.com/paw1a/golox/internal/resolving"
+	"github.com/paw1a/golox/internal/runtime"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Status int
+
+const (
+	Pass Status = iota
+	Fail
+	Error
+)
+
+func (s Status) String() string {
+	switch s {
+	case Pass:
+		return "PASS"
+	case Fail:
+		return "FAIL"
+	}
+	return "ERROR"
+}
+
+// Result is the outcome of a single test function. A test fails when an
+// assertion does not hold and errors on any other runtime error.
+type Result struct {
+	Name     string
+	Status   Status
+	Message  string
+	Output   string
+	Duration time.Duration
+}
+
+// Suite holds the results of the tests of one file. Err is set when the
+// file could not be read or parsed, in which case no test was run.
+type Suite struct {
+	File     string
+	Err      error
+	Results  []Result
+	Duration time.Duration
+}
+
+func (s Suite) Count(status Status) int {
+	count := 0
+	for _, result := range s.Results {
+		if result.Status == status {
+			count++
+		}
+	}
+	return count
+}
+
+func (s Suite) Passed() bool {
+	return s.Err == nil && s.Count(Pass) == len(s.Results)
+}
+
+// Discover returns the test files found in the given paths: files are taken
+// as they are and directories are searched recursively for *_test.lox.
+func Discover(paths ...string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		err = filepath.WalkDir(path, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !entry.IsDir() && strings.HasSuffix(entry.Name(), "_test.lox") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// RunFile runs every global function of the file whose name starts with
+// test_, each one in a fresh interpreter which first executes the top level
+// statements of the file.
+func RunFile(filename string) (suite Suite) {
+	suite.File = filename
+	start := time.Now()
+	defer func() {
+		suite.Duration = time.Since(start)
+	}()
+
+	source, err := ioutil.ReadFile(filename)
+	if err != nil {
+		suite.Err = err
+		return suite
+	}
+
+	statements, err := parse(string(source))
+	if err != nil {
+		suite.Err = err
+		return suite
+	}
+
+	for _, stmt := range statements {
+		switch stmt.(type) {
+		case ast.FunDeclarationStmt:
+			declaration := stmt.(ast.FunDeclarationStmt)
+			if strings.HasPrefix(declaration.Name.Lexeme, "test_") {
+				suite.Results = append(suite.Results, runTest(statements, declaration))
+			}
+		}
+	}
+
+	return suite
+}
+
+func parse(source string) ([]ast.Stmt, error) {
+	lexer := lexing.NewLexer(source)
+	lexer.ScanTokens()
+	if len(lexer.Errors) != 0 {
+		return nil, joinErrors(lexer.Errors)
+	}
+
+	parser := parsing.NewParser(lexer.Tokens, lexer.Lines)
+	statements := parser.Parse()
+	if len(parser.Errors) != 0 {
+		return nil, joinErrors(parser.Errors)
+	}
+
+	resolver := resolving.NewResolver()
+	resolver.Resolve(statements)
+	if errs := resolver.Errors(); len(errs) != 0 {
+		return nil, joinErrors(errs)
+	}
+
+	return statements, nil
+}
+
+func joinErrors(errors []error) error {
+	messages := make([]string, len(errors))
+	for i, err := range errors {
+		messages[i] = strings.TrimRight(err.Error(), "\n")
+	}
+	return fmt.Errorf("%s", strings.Join(messages, "\n"))
+}
+
+func runTest(statements []ast.Stmt, test ast.FunDeclarationStmt) (result Result) {
+	result.Name = test.Name.Lexeme
+	if len(test.Params) != 0 {
+		result.Status = Error
+		result.Message = fmt.Sprintf("line %d | test function must not take parameters", test.Name.Line)
+		return result
+	}
+
+	var output bytes.Buffer
+	inter := runtime.NewInterpreter()
+	inter.SetOutput(&output)
+	for name, native := range natives {
+		inter.Define(name, native)
+	}
+
+	start := time.Now()
+	defer func() {
+		result.Duration = time.Since(start)
+		result.Output = output.String()
+
+		if r := recover(); r != nil {
+			switch r.(type) {
+			case *Failure:
+				result.Status = Fail
+			default:
+				result.Status = Error
+			}
+			result.Message = fmt.Sprintf("%v", r)
+		}
+	}()
+
+	for _, stmt := range statements {
+		inter.Execute(stmt)
+	}
+
+	value, _ := inter.Globals().Lookup(test.Name.Lexeme)
+	function, ok := value.(runtime.Caller)
+	if !ok {
+		panic(runtime.NewError(test.Name, "test is not a function"))
+	}
+	inter.Call(function)
+
+	return result
+}
@@ -0,0 +1,67 @@
+package testrunner
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestRunFile(t *testing.T) {
+	suite := RunFile(filepath.Join("testdata", "assert_test.lox"))
+	if suite.Err != nil {
+		t.Fatalf("RunFile: %v", suite.Err)
+	}
+
+	tests := []struct {
+		name    string
+		status  Status
+		message string
+		output  string
+	}{
+		{"test_pass", Pass, "", ""},
+		{"test_assert_fails", Fail, "line 10 | flag: assertion failed, got false", "before\n"},
+		{"test_equal_fails", Fail, "line 15 | values are not equal\n--- expected\n+++ actual\n  [\n    1,\n+   2,\n    3,\n  ]", ""},
+		{"test_throws", Pass, "", ""},
+		{"test_throws_fails", Fail, "line 24 | expected an error, the function returned normally", ""},
+		{"test_error", Error, "line 28 | at ')': invalid object to call", ""},
+		{"test_params", Error, "line 31 | test function must not take parameters", ""},
+	}
+	if len(suite.Results) != len(tests) {
+		t.Fatalf("got %d results, want %d: %+v", len(suite.Results), len(tests), suite.Results)
+	}
+
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := suite.Results[i]
+			if result.Name != test.name || result.Status != test.status {
+				t.Fatalf("result = %s %s, want %s %s", result.Name, result.Status, test.name, test.status)
+			}
+			if result.Message != test.message {
+				t.Errorf("message = %q, want %q", result.Message, test.message)
+			}
+			if result.Output != test.output {
+				t.Errorf("output = %q, want %q", result.Output, test.output)
+			}
+		})
+	}
+
+	if suite.Passed() {
+		t.Error("suite with failures passed")
+	}
+}
+
+func TestRunFileSyntaxError(t *testing.T) {
+	suite := RunFile(filepath.Join("testdata", "syntax_error.lox"))
+	if suite.Err == nil || len(suite.Results) != 0 {
+		t.Errorf("RunFile = %+v, want an error and no results", suite)
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	files, err := Discover("testdata")
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+	if want := filepath.Join("testdata", "assert_test.lox"); len(files) != 1 || files[0] != want {
+		t.Errorf("Discover = %q, want [%q]", files, want)
+	}
+}